@@ -14,10 +14,14 @@ const (
 	GLOBAL_TARSUM_INDEX     = "GLOBAL_TARSUM_INDEX"
 	GLOBAL_TAG_INDEX        = "GLOBAL_TAG_INDEX"
 	GLOBAL_COMPOSE_INDEX    = "GLOBAL_COMPOSE_INDEX"
+	GLOBAL_UPLOAD_INDEX     = "GLOBAL_UPLOAD_INDEX"
+	GLOBAL_MANIFEST_INDEX   = "GLOBAL_MANIFEST_INDEX"
 	//Sail Data Index
 	GLOBAL_USER_INDEX         = "GLOBAL_USER_INDEX"
 	GLOBAL_ORGANIZATION_INDEX = "GLOBAL_ORGANIZATION_INDEX"
 	GLOBAL_TEAM_INDEX         = "GLOBAL_TEAM_INDEX"
+	GLOBAL_ROBOT_INDEX        = "GLOBAL_ROBOT_INDEX"
+	GLOBAL_TOKEN_INDEX        = "GLOBAL_TOKEN_INDEX"
 	//Wharf Data Index
 	GLOBAL_ADMIN_INDEX = "GLOBAL_ADMIN_INDEX"
 	GLOBAL_LOG_INDEX   = "GLOBAL_LOG_INDEX"
@@ -27,10 +31,19 @@ const (
   [user] : USER-(username)
 	[organization] : ORG-(org)
 	[team] : TEAM-(org)-(team)
+	[robot] : ROBOT-(namespace)-(robot)
+	[robots] : ROBOTS-(namespace)
+	[token] : TOKEN-(username)-(id)
+	[tokens] : TOKENS-(username)
 	[repository] : REPO-(namespace)-(repo)
 	[image] : IMAGE-(imageId)
 	[tag] : TAG-(namespace)-(repo)-(tag)
+	[blobs] : BLOBS-(namespace)-(repo)
+	[manifest] : MANIFEST-(namespace)-(repo)-(digest)
+	[referrers] : REFERRERS-(namespace)-(repo)-(digest)
+	[permission] : PERMISSION-(namespace)-(repo)
 	[compose] : COMPOSE-(namespace)-(compose)
+	[upload] : UPLOAD-(uuid)
 	[admin] : ADMIN-(username)
 	[log] : LOG-(object)
 	[lock] : LOCK-(object)
@@ -52,7 +65,19 @@ func Key(object string, keys ...string) (result string) {
 		result = fmt.Sprintf("ORG-%s", keys[0])
 	case "TEAM":
 	case "team":
-		result = fmt.Sprintf("ORG-%s-%s", keys[0], keys[1])
+		result = fmt.Sprintf("TEAM-%s-%s", keys[0], keys[1])
+	case "ROBOT":
+	case "robot":
+		result = fmt.Sprintf("ROBOT-%s-%s", keys[0], keys[1])
+	case "ROBOTS":
+	case "robots":
+		result = fmt.Sprintf("ROBOTS-%s", keys[0])
+	case "TOKEN":
+	case "token":
+		result = fmt.Sprintf("TOKEN-%s-%s", keys[0], keys[1])
+	case "TOKENS":
+	case "tokens":
+		result = fmt.Sprintf("TOKENS-%s", keys[0])
 	case "REPO":
 	case "REPOSITORY":
 	case "repo":
@@ -67,9 +92,24 @@ func Key(object string, keys ...string) (result string) {
 	case "TAG":
 	case "tag":
 		result = fmt.Sprintf("TAG-%s-%s-%s", keys[0], keys[1], keys[2])
+	case "MANIFEST":
+	case "manifest":
+		result = fmt.Sprintf("MANIFEST-%s-%s-%s", keys[0], keys[1], keys[2])
+	case "PERMISSION":
+	case "permission":
+		result = fmt.Sprintf("PERMISSION-%s-%s", keys[0], keys[1])
+	case "REFERRERS":
+	case "referrers":
+		result = fmt.Sprintf("REFERRERS-%s-%s-%s", keys[0], keys[1], keys[2])
+	case "BLOBS":
+	case "blobs":
+		result = fmt.Sprintf("BLOBS-%s-%s", keys[0], keys[1])
 	case "COMPOSE":
 	case "compose":
 		result = fmt.Sprintf("COMPOSE-%s-%s", keys[0], keys[1])
+	case "UPLOAD":
+	case "upload":
+		result = fmt.Sprintf("UPLOAD-%s", keys[0])
 	case "ADMIN":
 	case "admin":
 		result = fmt.Sprintf("ADMIN-%s", keys[0])
@@ -25,6 +25,23 @@ type EndpointDesc struct {
 	Disabled  bool          `json:"disabled"`
 }
 
+type ReplicationCtx struct {
+	Name  string            `json:"name,omitempty"`
+	Rules []ReplicationRule `json:"rules,omitempty"`
+}
+
+type ReplicationRule struct {
+	Name         string   `json:"name"`
+	URL          string   `json:"url"`
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	Repositories []string `json:"repositories"`
+	Tags         []string `json:"tags"`
+	Threshold    int      `json:"threshold"`
+	BackoffMs    int      `json:"backoff"` // milliseconds before the first retry
+	Disabled     bool     `json:"disabled"`
+}
+
 type AuthorDesc map[string]interface{}
 
 type AuthorsCtx map[string]AuthorDesc
@@ -32,6 +49,7 @@ type AuthorsCtx map[string]AuthorDesc
 type Desc struct {
 	Notifications NotificationsCtx `json:"notifications,omitempty"`
 	Authors       AuthorsCtx       `json:"auth,omitempty"`
+	Replication   ReplicationCtx   `json:"replication,omitempty"`
 }
 
 var JSONConfCtx Desc
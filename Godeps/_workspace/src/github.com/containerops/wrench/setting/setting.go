@@ -2,6 +2,8 @@ package setting
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/astaxie/beego/config"
 )
@@ -37,6 +39,24 @@ var (
 	RegistryVersion     string
 	DistributionVersion string
 	Standalone          string
+	UploadPurgeAge      time.Duration
+	UploadPurgeInterval time.Duration
+	DeleteEnabled       bool
+	SigningKey          string
+	DefaultNamespace    string
+	Administrators      []string
+	GCInterval          time.Duration
+	GCGracePeriod       time.Duration
+	//Proxy
+	ProxyRemoteURL   string
+	ProxyUsername    string
+	ProxyPassword    string
+	ProxyManifestTTL time.Duration
+	//Token
+	TokenRealm      string
+	TokenService    string
+	TokenIssuer     string
+	TokenExpiration time.Duration
 )
 
 // object storage driver config parameters
@@ -171,6 +191,109 @@ func SetConfig(path string) error {
 		err = fmt.Errorf("Standalone version value is null")
 	}
 
+	//Abandoned upload sessions older than purge age would be removed every purge interval, zero age disables purging
+	UploadPurgeAge, UploadPurgeInterval = 168*time.Hour, 24*time.Hour
+	if purgeage := conf.String("dockyard::uploadpurgeage"); purgeage != "" {
+		if age, e := time.ParseDuration(purgeage); e != nil {
+			err = fmt.Errorf("Upload purge age value is invalid: %v", e.Error())
+		} else {
+			UploadPurgeAge = age
+		}
+	}
+
+	if purgeinterval := conf.String("dockyard::uploadpurgeinterval"); purgeinterval != "" {
+		if interval, e := time.ParseDuration(purgeinterval); e != nil {
+			err = fmt.Errorf("Upload purge interval value is invalid: %v", e.Error())
+		} else {
+			UploadPurgeInterval = interval
+		}
+	}
+
+	//Deleting manifests and blobs through API is disabled by default
+	DeleteEnabled = false
+	if deleteenabled, e := conf.Bool("dockyard::deleteenabled"); e == nil {
+		DeleteEnabled = deleteenabled
+	}
+
+	//Server key to sign manifests, generated at first start if it doesn't exist
+	SigningKey = "cert/signing.key"
+	if signingkey := conf.String("dockyard::signingkey"); signingkey != "" {
+		SigningKey = signingkey
+	}
+
+	//Namespace of repositories pushed with a single component name, e.g. busybox
+	DefaultNamespace = "library"
+	if defaultnamespace := conf.String("dockyard::defaultnamespace"); defaultnamespace != "" {
+		DefaultNamespace = defaultnamespace
+	}
+
+	//Users who claim the namespaces already having repositories, e.g. library
+	Administrators = []string{}
+	if administrators := conf.String("dockyard::administrators"); administrators != "" {
+		for _, name := range strings.Split(administrators, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				Administrators = append(Administrators, name)
+			}
+		}
+	}
+
+	//Unreferenced blobs older than grace period would be removed every gc interval, zero interval disables scheduled gc
+	GCInterval, GCGracePeriod = 0, 24*time.Hour
+	if gcinterval := conf.String("dockyard::gcinterval"); gcinterval != "" {
+		if interval, e := time.ParseDuration(gcinterval); e != nil {
+			err = fmt.Errorf("GC interval value is invalid: %v", e.Error())
+		} else {
+			GCInterval = interval
+		}
+	}
+
+	if gcgraceperiod := conf.String("dockyard::gcgraceperiod"); gcgraceperiod != "" {
+		if grace, e := time.ParseDuration(gcgraceperiod); e != nil {
+			err = fmt.Errorf("GC grace period value is invalid: %v", e.Error())
+		} else {
+			GCGracePeriod = grace
+		}
+	}
+
+	//Pull through cache of upstream registry, disabled when remote url is empty
+	ProxyRemoteURL = conf.String("proxy::remoteurl")
+	ProxyUsername = conf.String("proxy::username")
+	ProxyPassword = conf.String("proxy::password")
+
+	ProxyManifestTTL = time.Hour
+	if manifestttl := conf.String("proxy::manifestttl"); manifestttl != "" {
+		if ttl, e := time.ParseDuration(manifestttl); e != nil {
+			err = fmt.Errorf("Proxy manifest ttl value is invalid: %v", e.Error())
+		} else {
+			ProxyManifestTTL = ttl
+		}
+	}
+
+	//Token service issues the bearer tokens checked by V2 API when standalone is false
+	TokenRealm = fmt.Sprintf("%v://%v/v2/token", ListenMode, Domains)
+	if realm := conf.String("token::realm"); realm != "" {
+		TokenRealm = realm
+	}
+
+	TokenService = Domains
+	if service := conf.String("token::service"); service != "" {
+		TokenService = service
+	}
+
+	TokenIssuer = AppName
+	if issuer := conf.String("token::issuer"); issuer != "" {
+		TokenIssuer = issuer
+	}
+
+	TokenExpiration = 5 * time.Minute
+	if expiration := conf.String("token::expiration"); expiration != "" {
+		if exp, e := time.ParseDuration(expiration); e != nil {
+			err = fmt.Errorf("Token expiration value is invalid: %v", e.Error())
+		} else {
+			TokenExpiration = exp
+		}
+	}
+
 	//Dockyard object storage,default to use dockyard storage
 	BackendDriver = "native"
 	if backenddriver := conf.String("dockyard::driver"); backenddriver != "" {
@@ -26,6 +26,7 @@ func init() {
 
 func InitFunc() {
 	drivers.InjectReflect.Bind("aliyunsave", aliyunsave)
+	drivers.InjectReflect.Bind("aliyundelete", aliyundelete)
 }
 
 
@@ -53,6 +54,19 @@ func aliyunsave(file string) (url string, err error) {
 	}
 }
 
+func aliyundelete(file string) error {
+
+	client := NewClient(setting.AccessKeyID, setting.AccessKeysecret)
+	bucket := NewBucket(setting.Bucket, setting.Endpoint, client)
+
+	var key string
+	for _, key = range strings.Split(file, "/") {
+
+	}
+
+	return bucket.Delete(key)
+}
+
 var resourceQSWhitelist []string = []string{
 	"acl",
 	"group",
@@ -129,6 +143,21 @@ func (b *Bucket) PutFile(object, filepath string, headers map[string]string) err
 	return b.Put(object, file, headers)
 }
 
+// DELETE the given `object`.
+func (b *Bucket) Delete(object string) error {
+	resp, err := b.do("DELETE", b.name, string(b.region), object, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 204 && resp.StatusCode != 200 {
+		return fmt.Errorf("Delete object failed: %v", resp.Status)
+	}
+
+	return nil
+}
+
 func (c *Client) do(method, bucket, region, object string, header http.Header, body io.Reader) (*http.Response, error) {
 	object = strings.Trim(object, "/")
 	req, err := http.NewRequest(method, fmt.Sprintf("http://%s.%s/%s", bucket, region, object), body)
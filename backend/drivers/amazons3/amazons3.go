@@ -22,6 +22,7 @@ func init() {
 
 func InitFunc() {
 	drivers.InjectReflect.Bind("amazons3save", amazons3save)
+	drivers.InjectReflect.Bind("amazons3delete", amazons3delete)
 }
 
 func amazons3save(file string) (url string, err error) {
@@ -63,6 +64,32 @@ func amazons3save(file string) (url string, err error) {
 
 }
 
+func amazons3delete(file string) error {
+
+	var key string
+
+	for _, key = range strings.Split(file, "/") {
+
+	}
+
+	requstUrl := "http://" + setting.Bucket + "." + setting.Endpoint + "/" + key
+	r, _ := http.NewRequest("DELETE", requstUrl, nil)
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+
+	amazons3Sign(r, key, setting.AccessKeyID, setting.AccessKeysecret)
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Delete object failed: %v", resp.Status)
+	}
+
+	return nil
+}
+
 func amazons3Sign(r *http.Request, key string, accessKeyId string, accessKeySecret string) {
 
 	plainText := amazons3cloudMakePlainText(r, key)
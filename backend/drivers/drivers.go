@@ -152,3 +152,25 @@ func Save(jsonIn string) (jsonOut string, err error) {
 
 	return string(jsonTempOut), nil
 }
+
+// Deletable reports whether the backend driver removes the objects it saves, drivers saving nothing have nothing to remove
+func Deletable() bool {
+	_, save := InjectReflect[setting.BackendDriver+"save"]
+	_, remove := InjectReflect[setting.BackendDriver+"delete"]
+
+	return !save || remove
+}
+
+// Delete removes the object saved from file by "<driver>save" in backend storage
+func Delete(file string) error {
+	rt, err := InjectReflect.Call(setting.BackendDriver+"delete", file)
+	if nil != err {
+		return err
+	}
+
+	if !rt[0].IsNil() {
+		return rt[0].Interface().(error)
+	}
+
+	return nil
+}
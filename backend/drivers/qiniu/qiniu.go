@@ -17,6 +17,7 @@ func init() {
 
 func InitFunc() {
 	drivers.InjectReflect.Bind("qiniusave", qiniusave)
+	drivers.InjectReflect.Bind("qiniudelete", qiniudelete)
 }
 
 func qiniusave(file string) (url string, err error) {
@@ -45,3 +46,17 @@ func qiniusave(file string) (url string, err error) {
 	}
 
 }
+
+func qiniudelete(file string) error {
+
+	var key string
+
+	for _, key = range strings.Split(file, "/") {
+
+	}
+
+	conf.ACCESS_KEY = setting.AccessKeyID
+	conf.SECRET_KEY = setting.AccessKeysecret
+
+	return rs.New(nil).Delete(nil, setting.Bucket, key)
+}
@@ -17,6 +17,7 @@ func init() {
 
 func InitFunc() {
 	drivers.InjectReflect.Bind("upyunsave", upyunsave)
+	drivers.InjectReflect.Bind("upyundelete", upyundelete)
 }
 
 func upyunsave(file string) (url string, err error) {
@@ -50,3 +51,21 @@ func upyunsave(file string) (url string, err error) {
 	}
 	return url, nil
 }
+
+func upyundelete(file string) error {
+
+	var key string
+
+	for _, key = range strings.Split(file, "/") {
+
+	}
+
+	u := upyun.NewUpYun(setting.Bucket, setting.User, setting.Passwd)
+	if nil == u {
+		return errors.New("UpYun.NewUpYun Fail")
+	}
+
+	u.SetEndpoint(setting.Endpoint)
+
+	return u.Delete(key)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/codegangsta/cli"
+
+	"github.com/containerops/dockyard/backend"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/wrench/db"
+	"github.com/containerops/wrench/setting"
+)
+
+var CmdGC = cli.Command{
+	Name:        "gc",
+	Usage:       "remove blobs not referenced by any tag or manifest",
+	Description: "blobs referenced by neither tags nor manifests are removed from blob store and backend storage, blobs modified within grace period are kept for in-flight uploads.",
+	Action:      runGC,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "report blobs to be removed without removing them",
+		},
+		cli.DurationFlag{
+			Name:  "grace",
+			Usage: "keep blobs modified within grace period, default is gcgraceperiod in runtime config",
+		},
+	},
+}
+
+func runGC(c *cli.Context) {
+	if err := db.InitDB(setting.DBURI, setting.DBPasswd, setting.DBDB); err != nil {
+		fmt.Printf("Connect Database error %s", err.Error())
+		return
+	}
+
+	if err := backend.InitBackend(); err != nil {
+		fmt.Printf("Init backend error %s", err.Error())
+		return
+	}
+
+	log := logs.NewLogger(10000)
+	log.SetLogger("console", "")
+
+	grace := setting.GCGracePeriod
+	if c.IsSet("grace") {
+		grace = c.Duration("grace")
+	}
+
+	if err := module.GarbageCollect(grace, c.Bool("dry-run"), log); err != nil {
+		fmt.Printf("Garbage collect error %s", err.Error())
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/codegangsta/cli"
+
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/wrench/db"
+	"github.com/containerops/wrench/setting"
+)
+
+var CmdMigrate = cli.Command{
+	Name:        "migrate",
+	Usage:       "move layers into content addressable blob store",
+	Description: "layers stored in tarsum and image directories by earlier versions are moved into blob store by their sha256 digest.",
+	Action:      runMigrate,
+}
+
+func runMigrate(c *cli.Context) {
+	if err := db.InitDB(setting.DBURI, setting.DBPasswd, setting.DBDB); err != nil {
+		fmt.Printf("Connect Database error %s", err.Error())
+		return
+	}
+
+	log := logs.NewLogger(10000)
+	log.SetLogger("console", "")
+
+	if err := module.MigrateBlobs(log); err != nil {
+		fmt.Printf("Migrate blobs error %s", err.Error())
+	}
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+)
+
+const (
+	//days before access tokens expire
+	defaultTokenExpiration = 90
+	maxTokenExpiration     = 365
+)
+
+type accessTokenDesc struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int      `json:"expires_in"`
+}
+
+// GetAccessTokensHandler lists the access tokens of the user who logs in, without the tokens themselves
+func GetAccessTokensHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	if status, result := checkManager(log, account); status != http.StatusOK {
+		return status, result
+	}
+
+	tokens, err := models.ListAccessTokens(account.Name)
+	if err != nil {
+		log.Error("[TOKEN] List access tokens of %v failed: %v", account.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "List access tokens failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	for i := range tokens {
+		tokens[i].Token = ""
+	}
+
+	result, _ := json.Marshal(tokens)
+	return http.StatusOK, result
+}
+
+// PostAccessTokenHandler creates an access token of the user who logs in,
+// the token is only returned in the response and it's used as the password of user.
+func PostAccessTokenHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, body *middleware.RequestBody) (int, []byte) {
+	if status, result := checkManager(log, account); status != http.StatusOK {
+		return status, result
+	}
+
+	var desc accessTokenDesc
+	if data, err := body.Bytes(); err != nil || json.Unmarshal(data, &desc) != nil || desc.Name == "" {
+		result, _ := json.Marshal(map[string]string{"message": "Invalid access token, a name is required"})
+		return http.StatusBadRequest, result
+	}
+
+	if !validScopes(desc.Scopes) {
+		result, _ := json.Marshal(map[string]string{"message": "Scopes should be some of pull, push and delete"})
+		return http.StatusBadRequest, result
+	}
+
+	if desc.ExpiresIn == 0 {
+		desc.ExpiresIn = defaultTokenExpiration
+	} else if desc.ExpiresIn < 0 || desc.ExpiresIn > maxTokenExpiration {
+		result, _ := json.Marshal(map[string]string{"message": "Access tokens expire in 1 to 365 days"})
+		return http.StatusBadRequest, result
+	}
+
+	t := new(models.AccessToken)
+	token, err := t.Put(account.Name, desc.Name, desc.Scopes, time.Duration(desc.ExpiresIn)*24*time.Hour)
+	if err != nil {
+		log.Error("[TOKEN] Save access token of %v failed: %v", account.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Save access token failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[TOKEN] Access token %v of %v created", t.ID, account.Name)
+
+	t.Token = token
+	result, _ := json.Marshal(t)
+	return http.StatusCreated, result
+}
+
+func DeleteAccessTokenHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	if status, result := checkManager(log, account); status != http.StatusOK {
+		return status, result
+	}
+
+	id := ctx.Params(":id")
+
+	t := new(models.AccessToken)
+	if has, _, err := t.Has(account.Name, id); err != nil {
+		log.Error("[TOKEN] Get access token %v of %v failed: %v", id, account.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get access token failed"})
+		return http.StatusInternalServerError, result
+	} else if has == false {
+		result, _ := json.Marshal(map[string]string{"message": "Access token not found"})
+		return http.StatusNotFound, result
+	}
+
+	if err := t.Delete(); err != nil {
+		log.Error("[TOKEN] Revoke access token %v of %v failed: %v", id, account.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Revoke access token failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[TOKEN] Access token %v of %v revoked", id, account.Name)
+
+	return http.StatusNoContent, []byte{}
+}
+
+// checkManager responds to the requests managing credentials which aren't sent by a user of Dockyard with password
+func checkManager(log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	if account.Name == "" {
+		result, _ := json.Marshal(map[string]string{"message": "Authentication required"})
+		return http.StatusUnauthorized, result
+	} else if account.Limited() {
+		result, _ := json.Marshal(map[string]string{"message": "Access tokens and robots can't manage accounts"})
+		return http.StatusForbidden, result
+	}
+
+	u := new(models.User)
+	if has, _, err := u.Has(account.Name); err != nil {
+		log.Error("[TOKEN] Get user %v failed: %v", account.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get user failed"})
+		return http.StatusInternalServerError, result
+	} else if has == false {
+		result, _ := json.Marshal(map[string]string{"message": "Only users signed up in Dockyard manage credentials"})
+		return http.StatusForbidden, result
+	}
+
+	return http.StatusOK, nil
+}
+
+func validScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+
+	actions := module.PermissionActions(models.PermissionAdmin)
+	for _, scope := range scopes {
+		valid := false
+		for _, action := range actions {
+			if scope == action {
+				valid = true
+			}
+		}
+
+		if valid == false {
+			return false
+		}
+	}
+
+	return true
+}
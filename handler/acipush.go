@@ -17,6 +17,7 @@ import (
 	"github.com/astaxie/beego/logs"
 	"gopkg.in/macaron.v1"
 
+	"github.com/containerops/dockyard/middleware"
 	"github.com/containerops/wrench/setting"
 )
 
@@ -52,6 +53,7 @@ type completeMsg struct {
 type upload struct {
 	Started time.Time
 	Image   string
+	Account string //user who started the upload
 	GotSig  bool
 	GotACI  bool
 	GotMan  bool
@@ -117,7 +119,7 @@ func GetPubkeys(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 	return http.StatusOK, pubkey
 }
 
-func InitiateUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func InitiateUpload(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
 	image := ctx.Params(":image")
 	if image == "" {
 		log.Error("[ACI API]Get image name failed")
@@ -125,7 +127,7 @@ func InitiateUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 		return http.StatusNotFound, result
 	}
 
-	uploadNum := strconv.Itoa(newUpload(image))
+	uploadNum := strconv.Itoa(newUpload(image, account.Name))
 
 	var prefix string
 	prefix = setting.ListenMode+"://" + setting.Domains + "/ac-push" 
@@ -144,13 +146,21 @@ func InitiateUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 
 }
 
-func UploadManifest(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func UploadManifest(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
 	num, err := strconv.Atoi(ctx.Params(":num"))
 	if err != nil {
 		result, _ := json.Marshal(map[string]string{})
 		return http.StatusNotFound, result
 	}
 
+	up := getUpload(num)
+	if up == nil {
+		result, _ := json.Marshal(map[string]string{})
+		return http.StatusNotFound, result
+	} else if up.Account != account.Name {
+		return uploadDenied(log, num, account)
+	}
+
 	err = gotMan(num)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v", err)
@@ -161,7 +171,7 @@ func UploadManifest(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 	return http.StatusOK, result
 }
 
-func ReceiveSignUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func ReceiveSignUpload(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
 	num, err := strconv.Atoi(ctx.Params(":num"))
 	if err != nil {
 		result, _ := json.Marshal(map[string]string{})
@@ -172,6 +182,8 @@ func ReceiveSignUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte)
 	if up == nil {
 		result, _ := json.Marshal(map[string]string{})
 		return http.StatusNotFound, result
+	} else if up.Account != account.Name {
+		return uploadDenied(log, num, account)
 	}
 
 	_, err = os.Stat(up.Image)
@@ -211,7 +223,7 @@ func ReceiveSignUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte)
 	return http.StatusOK, result
 }
 
-func ReceiveAciUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func ReceiveAciUpload(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
 	num, err := strconv.Atoi(ctx.Params(":num"))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v", err)
@@ -224,6 +236,8 @@ func ReceiveAciUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 		fmt.Fprintf(os.Stderr, "%v", err)
 		result, _ := json.Marshal(map[string]string{})
 		return http.StatusNotFound, result
+	} else if up.Account != account.Name {
+		return uploadDenied(log, num, account)
 	}
 
 	_, err = os.Stat(up.Image)
@@ -271,7 +285,7 @@ func tmpACIPath(num int) string {
 	return path.Join(directory, "tmp", strconv.Itoa(num))
 }
 
-func CompleteUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func CompleteUpload(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
 	num, err := strconv.Atoi(ctx.Params(":num"))
 	if err != nil {
 		result, _ := json.Marshal(map[string]string{})
@@ -282,6 +296,8 @@ func CompleteUpload(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 	if up == nil {
 		result, _ := json.Marshal(map[string]string{})
 		return http.StatusNotFound, result
+	} else if up.Account != account.Name {
+		return uploadDenied(log, num, account)
 	}
 
 	body, err := ioutil.ReadAll(ctx.Req.Request.Body)
@@ -428,17 +444,25 @@ func finishUpload(num int) error {
 	return nil
 }
 
-func newUpload(image string) int {
+func newUpload(image, account string) int {
 	newuploadLock.Lock()
 	uploadcounter++
 	uploads[uploadcounter] = &upload{
 		Started: time.Now(),
 		Image:   image,
+		Account: account,
 	}
 	newuploadLock.Unlock()
 	return uploadcounter
 }
 
+// uploadDenied responds to the parts of an upload sent by someone else than the user who started it
+func uploadDenied(log *logs.BeeLogger, num int, account *middleware.Account) (int, []byte) {
+	log.Info("[ACI API]upload %d denied to %q", num, account.Name)
+	result, _ := json.Marshal(map[string]string{"message": "upload was started by another user"})
+	return http.StatusForbidden, result
+}
+
 func getUpload(num int) *upload {
 	var up *upload
 	newuploadLock.Lock()
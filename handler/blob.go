@@ -3,7 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -13,24 +13,46 @@ import (
 	"github.com/satori/go.uuid"
 	"gopkg.in/macaron.v1"
 
+	"github.com/containerops/dockyard/middleware"
 	"github.com/containerops/dockyard/models"
 	"github.com/containerops/dockyard/module"
+	"github.com/containerops/dockyard/module/errcode"
 	"github.com/containerops/wrench/setting"
 	"github.com/containerops/wrench/utils"
 )
 
 func HeadBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 	digest := ctx.Params(":digest")
-	tarsum := strings.Split(digest, ":")[1]
+
+	if parts := strings.SplitN(digest, ":", 2); len(parts) != 2 {
+		log.Error("[REGISTRY API V2] Invalid digest: %v", digest)
+
+		return errcode.DigestInvalid.Response(digest)
+	}
 
 	i := new(models.Image)
-	if has, _ := i.HasTarsum(tarsum); has == false {
-		log.Info("[REGISTRY API V2] Tarsum not found: %v", tarsum)
+	if has, err := hasBlob(i, ctx.Params(":namespace"), ctx.Params(":repository"), digest); err != nil {
+		log.Error("[REGISTRY API V2] Read blob link failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Read blob link failed")
+	} else if has == false && module.ProxyEnabled() {
+		size, err := module.ProxyStatBlob(ctx.Params(":namespace"), ctx.Params(":repository"), digest)
+		if err != nil {
+			log.Info("[REGISTRY API V2] Stat blob %v in upstream failed: %v", digest, err.Error())
 
-		result, _ := json.Marshal(map[string]string{"message": "Tarsum not found"})
-		return http.StatusNotFound, result
+			return errcode.BlobUnknown.Response(digest)
+		}
+
+		i.Size = size
+	} else if has == false {
+		log.Info("[REGISTRY API V2] Blob not found in %v/%v: %v", ctx.Params(":namespace"), ctx.Params(":repository"), digest)
+
+		return errcode.BlobUnknown.Response(digest)
 	}
 
+	//client checking the blob may push a manifest referencing it soon
+	module.TouchBlob(digest)
+
 	ctx.Resp.Header().Set("Content-Type", "application/x-gzip")
 	ctx.Resp.Header().Set("Docker-Content-Digest", digest)
 	ctx.Resp.Header().Set("Content-Length", fmt.Sprint(i.Size))
@@ -39,12 +61,56 @@ func HeadBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte)
 	return http.StatusOK, result
 }
 
-func PostBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func PostBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
 	namespace := ctx.Params(":namespace")
 	repository := ctx.Params(":repository")
 
+	//cross repository blob mount, the blob linked to the source repository is linked without upload when the account could read it,
+	//otherwise an upload is started as usual
+	if mount, from := ctx.Query("mount"), ctx.Query("from"); mount != "" && from != "" {
+		if mounted, err := mountBlob(account, namespace, repository, from, mount); err != nil {
+			log.Error("[REGISTRY API V2] Mount blob failed: %v", err.Error())
+
+			return errcode.Unknown.Response("Mount blob failed")
+		} else if mounted == true {
+			log.Info("[REGISTRY API V2] Mounted blob %v from %v to %v/%v", mount, from, namespace, repository)
+
+			random := fmt.Sprintf("%s://%s/v2/%s/%s/blobs/%s",
+				setting.ListenMode,
+				setting.Domains,
+				namespace,
+				repository,
+				mount)
+
+			ctx.Resp.Header().Set("Docker-Content-Digest", mount)
+			ctx.Resp.Header().Set("Location", random)
+			ctx.Resp.Header().Set("Content-Length", "0")
+
+			return http.StatusCreated, []byte("")
+		}
+
+		log.Info("[REGISTRY API V2] Blob %v isn't mounted from %v, fall back to upload", mount, from)
+	}
+
 	uuid := utils.MD5(uuid.NewV4().String())
-	state := utils.MD5(fmt.Sprintf("%s/%s/%s", namespace, repository, time.Now().UnixNano()/int64(time.Millisecond)))
+
+	imagePathTmp := fmt.Sprintf("%v/%v", setting.ImagePath, uuid)
+	layerfileTmp := fmt.Sprintf("%v/%v/layer", setting.ImagePath, uuid)
+
+	if err := os.MkdirAll(imagePathTmp, os.ModePerm); err != nil {
+		log.Error("[REGISTRY API V2] Create upload path failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Create upload path failed")
+	}
+
+	u := new(models.Upload)
+	if err := u.Put(uuid, namespace, repository, layerfileTmp); err != nil {
+		log.Error("[REGISTRY API V2] Save upload state failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Save upload state failed")
+	}
+
+	state := utils.MD5(fmt.Sprintf("%s/%s/%v", namespace, repository, time.Now().UnixNano()/int64(time.Millisecond)))
 	random := fmt.Sprintf("%s://%s/v2/%s/%s/blobs/uploads/%s?_state=%s",
 		setting.ListenMode,
 		setting.Domains,
@@ -68,27 +134,46 @@ func PatchBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte
 	desc := ctx.Params(":uuid")
 	uuid := strings.Split(desc, "?")[0]
 
-	imagePathTmp := fmt.Sprintf("%v/%v", setting.ImagePath, uuid)
-	layerfileTmp := fmt.Sprintf("%v/%v/layer", setting.ImagePath, uuid)
+	u := new(models.Upload)
+	if has, _, err := u.Has(uuid); err != nil {
+		log.Error("[REGISTRY API V2] Read upload state failed: %v", err.Error())
 
-	//saving specific tarsum every times is in order to split the same tarsum in HEAD handler
-	if !utils.IsDirExist(imagePathTmp) {
-		os.MkdirAll(imagePathTmp, os.ModePerm)
+		return errcode.Unknown.Response("Read upload state failed")
+	} else if has == false || u.Namespace != namespace || u.Repository != repository {
+		log.Info("[REGISTRY API V2] Upload not found: %v", uuid)
+
+		return errcode.BlobUploadUnknown.Response(uuid)
 	}
 
-	if _, err := os.Stat(layerfileTmp); err == nil {
-		os.Remove(layerfileTmp)
+	//the chunk must start at the end of the data received so far, otherwise client should resume from the reported range
+	offset := u.Offset
+	if contentRange := ctx.Req.Header.Get("Content-Range"); contentRange != "" {
+		start, _, err := module.ParseContentRange(contentRange)
+		if err != nil || start != u.Offset {
+			log.Error("[REGISTRY API V2] Invalid content range %v, current offset is %v", contentRange, u.Offset)
+
+			ctx.Resp.Header().Set("Docker-Upload-Uuid", uuid)
+			ctx.Resp.Header().Set("Range", uploadRange(u.Offset))
+
+			return errcode.RangeInvalid.Response(contentRange)
+		}
+		offset = start
 	}
 
-	data, _ := ctx.Req.Body().Bytes()
-	if err := ioutil.WriteFile(layerfileTmp, data, 0777); err != nil {
+	size, err := module.AppendImgLayer(u.Path, offset, ctx.Req.Body().ReadCloser())
+	if err != nil {
 		log.Error("[REGISTRY API V2] Save layerfile failed: %v", err.Error())
 
-		result, _ := json.Marshal(map[string]string{"message": "Save layerfile failed"})
-		return http.StatusBadRequest, result
+		return errcode.Unknown.Response("Save layerfile failed")
 	}
 
-	state := utils.MD5(fmt.Sprintf("%s/%s/%s", namespace, repository, time.Now().UnixNano()/int64(time.Millisecond)))
+	if err := u.PutOffset(uuid, offset+size); err != nil {
+		log.Error("[REGISTRY API V2] Save upload state failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Save upload state failed")
+	}
+
+	state := utils.MD5(fmt.Sprintf("%s/%s/%v", namespace, repository, time.Now().UnixNano()/int64(time.Millisecond)))
 	random := fmt.Sprintf("%s://%s/v2/%s/%s/blobs/uploads/%s?_state=%s",
 		setting.ListenMode,
 		setting.Domains,
@@ -99,48 +184,152 @@ func PatchBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte
 
 	ctx.Resp.Header().Set("Docker-Upload-Uuid", uuid)
 	ctx.Resp.Header().Set("Location", random)
-	ctx.Resp.Header().Set("Range", fmt.Sprintf("0-%v", len(data)-1))
+	ctx.Resp.Header().Set("Range", uploadRange(u.Offset))
 
 	result, _ := json.Marshal(map[string]string{})
 	return http.StatusAccepted, result
 }
 
+func GetBlobsUploadV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+	namespace := ctx.Params(":namespace")
+	repository := ctx.Params(":repository")
+	uuid := ctx.Params(":uuid")
+
+	u := new(models.Upload)
+	if has, _, err := u.Has(uuid); err != nil {
+		log.Error("[REGISTRY API V2] Read upload state failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Read upload state failed")
+	} else if has == false || u.Namespace != namespace || u.Repository != repository {
+		log.Info("[REGISTRY API V2] Upload not found: %v", uuid)
+
+		return errcode.BlobUploadUnknown.Response(uuid)
+	}
+
+	random := fmt.Sprintf("%s://%s/v2/%s/%s/blobs/uploads/%s",
+		setting.ListenMode,
+		setting.Domains,
+		namespace,
+		repository,
+		uuid)
+
+	ctx.Resp.Header().Set("Docker-Upload-Uuid", uuid)
+	ctx.Resp.Header().Set("Location", random)
+	ctx.Resp.Header().Set("Range", uploadRange(u.Offset))
+
+	return http.StatusNoContent, []byte("")
+}
+
+func DeleteBlobsUploadV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+	namespace := ctx.Params(":namespace")
+	repository := ctx.Params(":repository")
+	uuid := ctx.Params(":uuid")
+
+	u := new(models.Upload)
+	if has, _, err := u.Has(uuid); err != nil {
+		log.Error("[REGISTRY API V2] Read upload state failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Read upload state failed")
+	} else if has == false || u.Namespace != namespace || u.Repository != repository {
+		log.Info("[REGISTRY API V2] Upload not found: %v", uuid)
+
+		return errcode.BlobUploadUnknown.Response(uuid)
+	}
+
+	if err := os.RemoveAll(fmt.Sprintf("%v/%v", setting.ImagePath, uuid)); err != nil {
+		log.Error("[REGISTRY API V2] Remove upload path failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Remove upload path failed")
+	}
+
+	if err := u.Delete(uuid); err != nil {
+		log.Error("[REGISTRY API V2] Delete upload state failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Delete upload state failed")
+	}
+
+	return http.StatusNoContent, []byte("")
+}
+
 func PutBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+	namespace := ctx.Params(":namespace")
+	repository := ctx.Params(":repository")
+
 	desc := ctx.Params(":uuid")
 	uuid := strings.Split(desc, "?")[0]
 
 	digest := ctx.Query("digest")
+	if parts := strings.SplitN(digest, ":", 2); len(parts) != 2 || len(parts[1]) == 0 {
+		log.Error("[REGISTRY API V2] Invalid digest: %v", digest)
+
+		return errcode.DigestInvalid.Response(digest)
+	}
 	tarsum := strings.Split(digest, ":")[1]
 
+	//the last chunk of a chunked upload or the whole layer of a monolithic upload could be carried by PUT,
+	//both of them belong to an upload session started by POST, the uuid is never used in a path before it's found
+	u := new(models.Upload)
+	if has, _, err := u.Has(uuid); err != nil {
+		log.Error("[REGISTRY API V2] Read upload state failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Read upload state failed")
+	} else if has == false || u.Namespace != namespace || u.Repository != repository {
+		log.Info("[REGISTRY API V2] Upload not found: %v", uuid)
+
+		return errcode.BlobUploadUnknown.Response(uuid)
+	}
+
 	imagePathTmp := fmt.Sprintf("%v/%v", setting.ImagePath, uuid)
-	layerfileTmp := fmt.Sprintf("%v/%v/layer", setting.ImagePath, uuid)
-	imagePath := fmt.Sprintf("%v/tarsum/%v", setting.ImagePath, tarsum)
-	layerfile := fmt.Sprintf("%v/tarsum/%v/layer", setting.ImagePath, tarsum)
 
-	reqbody, _ := ctx.Req.Body().Bytes()
-	layerlen, err := module.CopyImgLayer(imagePathTmp, layerfileTmp, imagePath, layerfile, reqbody)
+	if _, err := module.AppendImgLayer(u.Path, u.Offset, ctx.Req.Body().ReadCloser()); err != nil {
+		log.Error("[REGISTRY API V2] Save layerfile failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Save layerfile failed")
+	}
+
+	//never trust the digest from client, the assembled upload must be hashed before it is committed
+	if err := module.VerifyImgLayer(u.Path, digest); err != nil {
+		log.Error("[REGISTRY API V2] Verify layerfile failed: %v", err.Error())
+
+		os.RemoveAll(imagePathTmp)
+		u.Delete(uuid)
+
+		return errcode.DigestInvalid.Response(digest)
+	}
+
+	layerfile, layerlen, err := module.CommitBlob(u.Path, digest)
 	if err != nil {
 		log.Error("[REGISTRY API V2] Save layerfile failed: %v", err.Error())
 
-		result, _ := json.Marshal(map[string]string{"message": "Save layerfile failed"})
-		return http.StatusBadRequest, result
+		return errcode.Unknown.Response("Save layerfile failed")
+	}
+	os.RemoveAll(imagePathTmp)
+
+	if err := u.Delete(uuid); err != nil {
+		log.Error("[REGISTRY API V2] Delete upload state failed: %v", err.Error())
 	}
 
 	//saving specific tarsum every times is in order to split the same tarsum in HEAD handler
 	i := new(models.Image)
-	i.Path, i.Size = layerfile, int64(layerlen)
+	i.Path, i.Size = layerfile, layerlen
 	if err := i.PutTarsum(tarsum); err != nil {
 		log.Error("[REGISTRY API V2] Save tarsum failed: %v", err.Error())
 
-		result, _ := json.Marshal(map[string]string{"message": "Save tarsum failed"})
-		return http.StatusBadRequest, result
+		return errcode.Unknown.Response("Save tarsum failed")
+	}
+
+	r := new(models.Repository)
+	if err := r.PutBlob(namespace, repository, digest); err != nil {
+		log.Error("[REGISTRY API V2] Link blob to repository failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Link blob to repository failed")
 	}
 
 	random := fmt.Sprintf("%s://%s/v2/%s/%s/blobs/%s",
 		setting.ListenMode,
 		setting.Domains,
-		ctx.Params(":namespace"),
-		ctx.Params(":repository"),
+		namespace,
+		repository,
 		digest)
 
 	ctx.Resp.Header().Set("Docker-Content-Digest", digest)
@@ -150,39 +339,193 @@ func PutBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte)
 	return http.StatusCreated, result
 }
 
-func GetBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func GetBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) {
 	digest := ctx.Params(":digest")
 
-	tarsum := strings.Split(digest, ":")[1]
-
 	i := new(models.Image)
-	has, _ := i.HasTarsum(tarsum)
-	if has == false {
-		log.Error("[REGISTRY API V2] Digest not found: %v", tarsum)
+	if parts := strings.SplitN(digest, ":", 2); len(parts) != 2 {
+		log.Error("[REGISTRY API V2] Invalid digest: %v", digest)
+
+		errcode.DigestInvalid.Write(ctx.Resp, digest)
+		return
+	} else if has, err := hasBlob(i, ctx.Params(":namespace"), ctx.Params(":repository"), digest); err != nil {
+		log.Error("[REGISTRY API V2] Read blob link failed: %v", err.Error())
+
+		errcode.Unknown.Write(ctx.Resp, "Read blob link failed")
+		return
+	} else if has == false && module.ProxyEnabled() {
+		if err := module.ProxyBlob(ctx.Params(":namespace"), ctx.Params(":repository"), digest, ctx.Resp); err != nil {
+			log.Error("[REGISTRY API V2] Fetch blob %v from upstream failed: %v", digest, err.Error())
+
+			if !ctx.Resp.Written() {
+				errcode.BlobUnknown.Write(ctx.Resp, digest)
+			}
+		}
+		return
+	} else if has == false {
+		log.Error("[REGISTRY API V2] Digest not found in %v/%v: %v", ctx.Params(":namespace"), ctx.Params(":repository"), digest)
+
+		errcode.BlobUnknown.Write(ctx.Resp, digest)
+		return
+	}
 
-		result, _ := json.Marshal(map[string]string{"message": "Digest not found"})
-		return http.StatusNotFound, result
+	ctx.Resp.Header().Set("Content-Type", "application/x-gzip")
+	ctx.Resp.Header().Set("Docker-Content-Digest", digest)
+
+	if err := serveLayer(ctx, i.Path, i.URL, digest); err != nil {
+		log.Error("[REGISTRY API V2] Serve layer file failed: %v", err.Error())
+
+		errcode.BlobUnknown.Write(ctx.Resp, digest)
+	}
+}
+
+// serveLayer streams the layer from local file, or from the backend storage url when the local one is gone.
+// Range, If-Range and conditional requests are handled against the etag, so interrupted pulls could resume.
+func serveLayer(ctx *macaron.Context, layerfile, url, etag string) error {
+	ctx.Resp.Header().Set("ETag", fmt.Sprintf("\"%v\"", etag))
+	ctx.Resp.Header().Set("Accept-Ranges", "bytes")
+
+	fd, err := os.Open(layerfile)
+	if err == nil {
+		defer fd.Close()
+
+		info, err := fd.Stat()
+		if err != nil {
+			return err
+		}
+
+		http.ServeContent(ctx.Resp, ctx.Req.Request, "", info.ModTime(), fd)
+		return nil
+	} else if url == "" {
+		return err
 	}
 
-	layerfile := i.Path
-	if _, err := os.Stat(layerfile); err != nil {
-		log.Error("[REGISTRY API V2] File path is invalid: %v", err.Error())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
 
-		result, _ := json.Marshal(map[string]string{"message": "File path is invalid"})
-		return http.StatusBadRequest, result
+	//the validator of backend is different from ours, so only pass the range through when If-Range matches
+	if ranges := ctx.Req.Header.Get("Range"); ranges != "" {
+		if ifrange := ctx.Req.Header.Get("If-Range"); ifrange == "" || ifrange == ctx.Resp.Header().Get("ETag") {
+			req.Header.Set("Range", ranges)
+		}
 	}
 
-	file, err := ioutil.ReadFile(layerfile)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Error("[REGISTRY API V2] Read file failed: %v", err.Error())
+		return err
+	}
+	defer resp.Body.Close()
 
-		result, _ := json.Marshal(map[string]string{"message": "Read file failed"})
-		return http.StatusBadRequest, result
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("Backend storage responds %v", resp.Status)
 	}
 
-	ctx.Resp.Header().Set("Content-Type", "application/x-gzip")
-	ctx.Resp.Header().Set("Docker-Content-Digest", digest)
-	ctx.Resp.Header().Set("Content-Length", fmt.Sprint(len(file)))
+	for _, header := range []string{"Content-Length", "Content-Range"} {
+		if value := resp.Header.Get(header); value != "" {
+			ctx.Resp.Header().Set(header, value)
+		}
+	}
+
+	ctx.Resp.WriteHeader(resp.StatusCode)
+	if ctx.Req.Method != "HEAD" {
+		io.Copy(ctx.Resp, resp.Body)
+	}
+
+	return nil
+}
+
+// hasBlob loads the layer of blob when it's linked to repository, blobs of other repositories are never served
+// so the layers of private repositories aren't pulled by digest through another one.
+func hasBlob(i *models.Image, namespace, repository, digest string) (bool, error) {
+	r := new(models.Repository)
+	if linked, err := r.HasBlob(namespace, repository, digest); err != nil || linked == false {
+		return false, err
+	}
 
-	return http.StatusOK, file
+	//a missing tarsum is not an error of database
+	has, _ := i.HasTarsum(strings.SplitN(digest, ":", 2)[1])
+	return has, nil
+}
+
+// mountBlob links the blob of repository from to the repository when it's linked to from and account could read from
+func mountBlob(account *middleware.Account, namespace, repository, from, digest string) (bool, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 {
+		return false, nil
+	}
+
+	fromNamespace, fromRepository := module.SplitRepositoryName(from)
+
+	r := new(models.Repository)
+	if has, err := r.HasBlob(fromNamespace, fromRepository, digest); err != nil || has == false {
+		return false, err
+	}
+
+	if setting.Standalone != "true" {
+		level, err := module.RepositoryPermission(account.Name, fromNamespace, fromRepository)
+		if err != nil {
+			return false, err
+		} else if !module.PermissionAllows(level, models.PermissionRead) || !account.Allows("pull") {
+			return false, nil
+		}
+	}
+
+	i := new(models.Image)
+	if has, _ := i.HasTarsum(parts[1]); has == false {
+		return false, nil
+	}
+
+	module.TouchBlob(digest)
+
+	return true, r.PutBlob(namespace, repository, digest)
+}
+
+// uploadRange returns the Range header value of the data received so far in an upload session
+func uploadRange(offset int64) string {
+	if offset > 0 {
+		offset = offset - 1
+	}
+
+	return fmt.Sprintf("0-%v", offset)
+}
+
+// DeleteBlobsV2Handler unlinks the blob from repository, the layer file is kept because it may be shared by other repositories
+func DeleteBlobsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+	namespace := ctx.Params(":namespace")
+	repository := ctx.Params(":repository")
+	digest := ctx.Params(":digest")
+
+	if setting.DeleteEnabled == false {
+		return errcode.Unsupported.Response(nil)
+	}
+
+	if parts := strings.SplitN(digest, ":", 2); len(parts) != 2 {
+		log.Error("[REGISTRY API V2] Invalid digest: %v", digest)
+
+		return errcode.DigestInvalid.Response(digest)
+	}
+
+	r := new(models.Repository)
+	if has, err := r.HasBlob(namespace, repository, digest); err != nil {
+		log.Error("[REGISTRY API V2] Read blob link failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Read blob link failed")
+	} else if has == false {
+		log.Error("[REGISTRY API V2] Blob not found in %v/%v: %v", namespace, repository, digest)
+
+		return errcode.BlobUnknown.Response(digest)
+	}
+
+	if err := r.DeleteBlob(namespace, repository, digest); err != nil {
+		log.Error("[REGISTRY API V2] Delete blob link failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Delete blob link failed")
+	}
+
+	log.Info("[REGISTRY API V2] Deleted blob %v of %v/%v", digest, namespace, repository)
+
+	result, _ := json.Marshal(map[string]string{})
+	return http.StatusAccepted, result
 }
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/dockyard/module/errcode"
+	"github.com/containerops/wrench/setting"
+)
+
+func GetCatalogV2Handler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	n, err := paginationNumber(ctx)
+	if err != nil {
+		log.Error("[REGISTRY API V2] Invalid pagination number: %v", ctx.Query("n"))
+
+		return errcode.PaginationNumberInvalid.Response(ctx.Query("n"))
+	}
+
+	r := new(models.Repository)
+	names, err := r.List()
+	if err != nil {
+		log.Error("[REGISTRY API V2] List repositories failed: %v", err.Error())
+
+		return errcode.Unknown.Response("List repositories failed")
+	}
+
+	//private repositories are only listed to users who could read them
+	if setting.Standalone != "true" {
+		readable := []string{}
+		for _, name := range names {
+			namespace, repository := module.SplitRepositoryName(name)
+			if level, err := module.RepositoryPermission(account.Name, namespace, repository); err != nil {
+				log.Error("[REGISTRY API V2] Get permission of %v failed: %v", name, err.Error())
+
+				return errcode.Unknown.Response("List repositories failed")
+			} else if module.PermissionAllows(level, models.PermissionRead) {
+				readable = append(readable, name)
+			}
+		}
+
+		names = readable
+	}
+
+	repositories, more := module.Paginate(names, ctx.Query("last"), n)
+	if more && n > 0 {
+		ctx.Resp.Header().Set("Link", module.PaginationLink(ctx.Req.URL.Path, repositories[len(repositories)-1], n))
+	}
+
+	result, _ := json.Marshal(map[string][]string{"repositories": repositories})
+	return http.StatusOK, result
+}
+
+// paginationNumber returns the n query of request, -1 when it's absent which means no limit
+func paginationNumber(ctx *macaron.Context) (int, error) {
+	if ctx.Query("n") == "" {
+		return -1, nil
+	}
+
+	n, err := strconv.Atoi(ctx.Query("n"))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Invalid pagination number: %v", ctx.Query("n"))
+	}
+
+	return n, nil
+}
@@ -3,17 +3,15 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/astaxie/beego/logs"
 	"gopkg.in/macaron.v1"
 
 	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
 	"github.com/containerops/wrench/setting"
-	"github.com/containerops/wrench/utils"
 )
 
 func GetImageAncestryV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
@@ -66,7 +64,7 @@ func GetImageJSONV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []by
 	return http.StatusOK, []byte(jsonInfo)
 }
 
-func GetImageLayerV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func GetImageLayerV1Handler(ctx *macaron.Context, log *logs.BeeLogger) {
 	imageId := ctx.Params(":imageId")
 
 	i := new(models.Image)
@@ -74,34 +72,27 @@ func GetImageLayerV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []b
 		log.Error("[REGISTRY API V1] Read Image Layer File Status Error: %v", err.Error())
 
 		result, _ := json.Marshal(map[string]string{"message": "Read Image Layer file Error"})
-		return http.StatusBadRequest, result
+		ctx.Resp.WriteHeader(http.StatusBadRequest)
+		ctx.Resp.Write(result)
+		return
 	} else if has == false {
 		log.Error("[REGISTRY API V1] Read Image None Error")
 
 		result, _ := json.Marshal(map[string]string{"message": "Read Image None"})
-		return http.StatusNotFound, result
+		ctx.Resp.WriteHeader(http.StatusNotFound)
+		ctx.Resp.Write(result)
+		return
 	}
 
-	layerfile := i.Path
-	if _, err := os.Stat(layerfile); err != nil {
-		log.Error("[REGISTRY API V1] Read Image file state error: %v", err.Error())
-
-		result, _ := json.Marshal(map[string]string{"message": "Read Image file state error"})
-		return http.StatusBadRequest, result
-	}
+	ctx.Resp.Header().Set("Content-Type", "application/octet-stream")
 
-	file, err := ioutil.ReadFile(layerfile)
-	if err != nil {
+	if err := serveLayer(ctx, i.Path, i.URL, imageId); err != nil {
 		log.Error("[REGISTRY API V1] Read Image file error: %v", err.Error())
 
 		result, _ := json.Marshal(map[string]string{"message": "Read Image file error"})
-		return http.StatusBadRequest, result
+		ctx.Resp.WriteHeader(http.StatusNotFound)
+		ctx.Resp.Write(result)
 	}
-
-	ctx.Resp.Header().Set("Content-Type", "application/octet-stream")
-	ctx.Resp.Header().Set("Content-Length", fmt.Sprint(len(file)))
-
-	return http.StatusOK, file
 }
 
 func PutImageJSONV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
@@ -130,20 +121,9 @@ func PutImageJSONV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []by
 func PutImageLayerv1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 	imageId := ctx.Params(":imageId")
 
-	basePath := setting.ImagePath
-	imagePath := fmt.Sprintf("%v/images/%v", basePath, imageId)
-	layerfile := fmt.Sprintf("%v/images/%v/layer", basePath, imageId)
-
-	if !utils.IsDirExist(imagePath) {
-		os.MkdirAll(imagePath, os.ModePerm)
-	}
-
-	if _, err := os.Stat(layerfile); err == nil {
-		os.Remove(layerfile)
-	}
-
-	data, _ := ctx.Req.Body().Bytes()
-	if err := ioutil.WriteFile(layerfile, data, 0777); err != nil {
+	//layers are stored by content, so the same layer pushed by V1 and V2 is kept only once
+	_, layerfile, size, err := module.StoreBlob(ctx.Req.Body().ReadCloser())
+	if err != nil {
 		log.Error("[REGISTRY API V1] Put Image Layer File Error: %v", err.Error())
 
 		result, _ := json.Marshal(map[string]string{"message": "Put Image Layer File Error"})
@@ -151,7 +131,7 @@ func PutImageLayerv1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []b
 	}
 
 	i := new(models.Image)
-	if err := i.PutLayer(imageId, layerfile, true, int64(len(data))); err != nil {
+	if err := i.PutLayer(imageId, layerfile, true, size); err != nil {
 		log.Error("[REGISTRY API V1] Put Image Layer File Data Error: %v", err.Error())
 
 		result, _ := json.Marshal(map[string]string{"message": "Put Image Layer File Data Error"})
@@ -8,15 +8,15 @@ import (
 	"github.com/astaxie/beego/logs"
 	"gopkg.in/macaron.v1"
 
+	"github.com/containerops/dockyard/middleware"
 	"github.com/containerops/dockyard/models"
 	"github.com/containerops/dockyard/module"
+	"github.com/containerops/dockyard/module/errcode"
 	"github.com/containerops/wrench/setting"
 	"github.com/containerops/wrench/utils"
 )
 
-var ManifestCtx []byte
-
-func PutManifestsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func PutManifestsV2Handler(ctx *macaron.Context, log *logs.BeeLogger, body *middleware.RequestBody) (int, []byte) {
 	namespace := ctx.Params(":namespace")
 	repository := ctx.Params(":repository")
 
@@ -26,27 +26,30 @@ func PutManifestsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []by
 	if err := repo.Put(namespace, repository, "", agent, setting.APIVERSION_V2); err != nil {
 		log.Error("[REGISTRY API V2] Save repository failed: %v", err.Error())
 
-		result, _ := json.Marshal(map[string]string{"message": err.Error()})
-		return http.StatusBadRequest, result
-	}
-
-	if len(ManifestCtx) == 0 {
-		ManifestCtx, _ = ctx.Req.Body().Bytes()
+		return errcode.Unknown.Response("Save repository failed")
 	}
 
-	if err := module.ParseManifest(ManifestCtx); err != nil {
-		log.Error("[REGISTRY API V2] Decode Manifest Error: %v", err.Error())
+	data, err := body.Bytes()
+	if err != nil {
+		log.Error("[REGISTRY API V2] Read manifest body failed: %v", err.Error())
 
-		result, _ := json.Marshal(map[string]string{"message": "Manifest converted failed"})
-		return http.StatusBadRequest, result
+		return errcode.ManifestInvalid.Response("Read manifest body failed")
 	}
 
-	digest, err := utils.DigestManifest(ManifestCtx)
+	digest, err := module.ParseManifest(data, namespace, repository, ctx.Params(":tag"), ctx.Req.Header.Get("Content-Type"))
 	if err != nil {
-		log.Error("[REGISTRY API V2] Get manifest digest failed: %v", err.Error())
+		log.Error("[REGISTRY API V2] Decode Manifest Error: %v", err.Error())
 
-		result, _ := json.Marshal(map[string]string{"message": "Get manifest digest failed"})
-		return http.StatusBadRequest, result
+		switch e := err.(type) {
+		case module.BlobUnknownError:
+			return errcode.ManifestBlobUnknown.Response(e.Digest)
+		case module.DigestInvalidError:
+			return errcode.DigestInvalid.Response(e.Digest)
+		case module.ManifestUnverifiedError:
+			return errcode.ManifestUnverified.Response(e.Reason)
+		}
+
+		return errcode.ManifestInvalid.Response(err.Error())
 	}
 
 	random := fmt.Sprintf("%s://%s/v2/%s/%s/manifests/%s",
@@ -59,6 +62,12 @@ func PutManifestsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []by
 	ctx.Resp.Header().Set("Docker-Content-Digest", digest)
 	ctx.Resp.Header().Set("Location", random)
 
+	//tells clients the subject is indexed so they needn't maintain the referrers tag
+	m := new(models.Manifest)
+	if has, _, err := m.Has(namespace, repository, digest); err == nil && has == true && m.Subject != "" {
+		ctx.Resp.Header().Set("OCI-Subject", m.Subject)
+	}
+
 	result, _ := json.Marshal(map[string]string{})
 	return http.StatusAccepted, result
 }
@@ -67,12 +76,18 @@ func GetTagsListV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byt
 	namespace := ctx.Params(":namespace")
 	repository := ctx.Params(":repository")
 
+	n, err := paginationNumber(ctx)
+	if err != nil {
+		log.Error("[REGISTRY API V2] Invalid pagination number: %v", ctx.Query("n"))
+
+		return errcode.PaginationNumberInvalid.Response(ctx.Query("n"))
+	}
+
 	r := new(models.Repository)
 	if has, _, err := r.Has(namespace, repository); err != nil || has == false {
 		log.Error("[REGISTRY API V2] Repository not found: %v", repository)
 
-		result, _ := json.Marshal(map[string]string{"message": "Repository not found"})
-		return http.StatusNotFound, result
+		return errcode.NameUnknown.Response(fmt.Sprintf("%s/%s", namespace, repository))
 	}
 
 	data := map[string]interface{}{}
@@ -83,15 +98,19 @@ func GetTagsListV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byt
 	for _, value := range r.Tags {
 		t := new(models.Tag)
 		if err := t.GetByKey(value); err != nil {
+			//a broken tag shouldn't hide all the others of repository
 			log.Error("[REGISTRY API V2] Tag not found: %v", err.Error())
-
-			result, _ := json.Marshal(map[string]string{"message": "Tag not found"})
-			return http.StatusNotFound, result
+			continue
 		}
 
 		tags = append(tags, t.Name)
 	}
 
+	tags, more := module.Paginate(tags, ctx.Query("last"), n)
+	if more && n > 0 {
+		ctx.Resp.Header().Set("Link", module.PaginationLink(ctx.Req.URL.Path, tags[len(tags)-1], n))
+	}
+
 	data["tags"] = tags
 
 	result, _ := json.Marshal(data)
@@ -99,28 +118,171 @@ func GetTagsListV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byt
 }
 
 func GetManifestsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
-	t := new(models.Tag)
+	return negotiateManifest(ctx, log)
+}
 
+func HeadManifestsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+	status, _ := negotiateManifest(ctx, log)
+	return status, []byte("")
+}
+
+// negotiateManifest resolves the tag or digest of request to the manifest served to client and sets its headers
+func negotiateManifest(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 	namespace := ctx.Params(":namespace")
 	repository := ctx.Params(":repository")
-	if err := t.Get(namespace, repository, ctx.Params(":tag")); err != nil {
-		log.Error("[REGISTRY API V2] Manifest not found: %v", err.Error())
+	reference := ctx.Params(":tag")
+
+	//the cached manifest is still served when upstream is unreachable
+	if module.ProxyEnabled() && module.ProxyStale(namespace, repository, reference) {
+		if err := module.ProxyManifest(namespace, repository, reference); err != nil {
+			log.Warn("[REGISTRY API V2] Fetch manifest %v from upstream failed: %v", reference, err.Error())
+		}
+	}
+
+	has, manifest, mediatype, err := module.GetManifest(namespace, repository, reference)
+	if err != nil {
+		log.Error("[REGISTRY API V2] Read manifest failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Read manifest failed")
+	} else if has == false {
+		log.Error("[REGISTRY API V2] Manifest not found: %v", reference)
+
+		return errcode.ManifestUnknown.Response(reference)
+	}
+
+	//clients which don't accept manifest list or image index get the manifest of default platform by tag,
+	//manifest fetched by digest is always served as it is
+	if !module.IsDigest(reference) && module.IsManifestList(mediatype) && !module.AcceptsMediaType(ctx.Req.Header["Accept"], mediatype) {
+		child, err := module.SelectManifest(manifest, module.DefaultPlatformOS, module.DefaultPlatformArchitecture)
+		if err != nil {
+			log.Error("[REGISTRY API V2] Select manifest from list failed: %v", err.Error())
+
+			return errcode.ManifestUnknown.Response(reference)
+		}
+
+		if module.ProxyEnabled() && module.ProxyStale(namespace, repository, child) {
+			if err := module.ProxyManifest(namespace, repository, child); err != nil {
+				log.Warn("[REGISTRY API V2] Fetch manifest %v from upstream failed: %v", child, err.Error())
+			}
+		}
+
+		m := new(models.Manifest)
+		if has, _, err := m.Has(namespace, repository, child); err != nil || has == false {
+			log.Error("[REGISTRY API V2] Manifest not found: %v", child)
+
+			return errcode.ManifestUnknown.Response(child)
+		}
+
+		manifest, mediatype = []byte(m.Manifest), m.MediaType
+	}
+
+	//old clients only understand schema1, the schema2 and OCI image manifests are converted for them when they're fetched by tag,
+	//manifest fetched by digest can't be rewritten because its digest would change
+	if (mediatype == module.ManifestV2MediaType || mediatype == module.OCIManifestMediaType) && !module.AcceptsMediaType(ctx.Req.Header["Accept"], mediatype) {
+		if module.IsDigest(reference) {
+			log.Error("[REGISTRY API V2] Manifest %v is %v which client doesn't accept", reference, mediatype)
+
+			return errcode.ManifestUnknown.Response(reference)
+		}
+
+		converted, err := module.ConvertManifestV1(manifest, mediatype, namespace, repository, reference)
+		if _, ok := err.(module.ManifestUnconvertibleError); ok {
+			log.Error("[REGISTRY API V2] Manifest %v isn't served to client: %v", reference, err.Error())
 
-		result, _ := json.Marshal(map[string]string{"message": "Manifest not found"})
-		return http.StatusNotFound, result
+			return errcode.ManifestUnknown.Response(reference)
+		} else if err != nil {
+			log.Error("[REGISTRY API V2] Convert manifest to schema1 failed: %v", err.Error())
+
+			return errcode.Unknown.Response("Convert manifest to schema1 failed")
+		}
+
+		manifest, mediatype = converted, module.SignedManifestV1MediaType
 	}
 
-	digest, err := utils.DigestManifest([]byte(t.Manifest))
+	digest, err := utils.DigestManifest(manifest)
 	if err != nil {
 		log.Error("[REGISTRY API V2] Get manifest digest failed: %v", err.Error())
 
-		result, _ := json.Marshal(map[string]string{"message": "Get manifest digest failed"})
-		return http.StatusBadRequest, result
+		return errcode.Unknown.Response("Get manifest digest failed")
 	}
 
-	ctx.Resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+	ctx.Resp.Header().Set("Content-Type", mediatype)
 	ctx.Resp.Header().Set("Docker-Content-Digest", digest)
-	ctx.Resp.Header().Set("Content-Length", fmt.Sprint(len(t.Manifest)))
+	ctx.Resp.Header().Set("Content-Length", fmt.Sprint(len(manifest)))
+
+	return http.StatusOK, manifest
+}
+
+func DeleteManifestsV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+	namespace := ctx.Params(":namespace")
+	repository := ctx.Params(":repository")
+	reference := ctx.Params(":tag")
+
+	if setting.DeleteEnabled == false {
+		return errcode.Unsupported.Response(nil)
+	}
+
+	//manifest could only be deleted by digest, all tags pointing to it are removed together
+	if !module.IsDigest(reference) {
+		log.Error("[REGISTRY API V2] Delete manifest by tag is unsupported: %v", reference)
+
+		return errcode.Unsupported.Response(reference)
+	}
+
+	if has, _, _, err := module.GetManifest(namespace, repository, reference); err != nil {
+		log.Error("[REGISTRY API V2] Read manifest failed: %v", err.Error())
 
-	return http.StatusOK, []byte(t.Manifest)
+		return errcode.Unknown.Response("Read manifest failed")
+	} else if has == false {
+		log.Error("[REGISTRY API V2] Manifest not found: %v", reference)
+
+		return errcode.ManifestUnknown.Response(reference)
+	}
+
+	r := new(models.Repository)
+	if has, _, err := r.Has(namespace, repository); err != nil {
+		log.Error("[REGISTRY API V2] Read repository failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Read repository failed")
+	} else if has == true {
+		tags := []string{}
+		for _, key := range r.Tags {
+			t := new(models.Tag)
+			if err := t.GetByKey(key); err != nil {
+				continue
+			}
+
+			if digest, err := utils.DigestManifest([]byte(t.Manifest)); err == nil && digest == reference {
+				tags = append(tags, t.Name)
+			}
+		}
+
+		for _, tag := range tags {
+			if err := r.DeleteTag(namespace, repository, tag); err != nil {
+				log.Error("[REGISTRY API V2] Delete tag %v failed: %v", tag, err.Error())
+
+				return errcode.Unknown.Response("Delete tag failed")
+			}
+		}
+	}
+
+	//the manifest is loaded so it's also removed from the referrers of its subject
+	m := new(models.Manifest)
+	if _, _, err := m.Has(namespace, repository, reference); err != nil {
+		log.Error("[REGISTRY API V2] Read manifest failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Read manifest failed")
+	}
+
+	m.Namespace, m.Repository, m.Digest = namespace, repository, reference
+	if err := m.Delete(); err != nil {
+		log.Error("[REGISTRY API V2] Delete manifest failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Delete manifest failed")
+	}
+
+	log.Info("[REGISTRY API V2] Deleted manifest %v of %v/%v", reference, namespace, repository)
+
+	result, _ := json.Marshal(map[string]string{})
+	return http.StatusAccepted, result
 }
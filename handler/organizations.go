@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+)
+
+type organizationDesc struct {
+	Name string `json:"name"`
+}
+
+func PostOrganizationHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, body *middleware.RequestBody) (int, []byte) {
+	if account.Name == "" {
+		result, _ := json.Marshal(map[string]string{"message": "Authentication required"})
+		return http.StatusUnauthorized, result
+	} else if account.Limited() {
+		result, _ := json.Marshal(map[string]string{"message": "Access tokens and robots can't manage accounts"})
+		return http.StatusForbidden, result
+	}
+
+	var desc organizationDesc
+	if data, err := body.Bytes(); err != nil || json.Unmarshal(data, &desc) != nil || !module.ValidAccountName(desc.Name) {
+		log.Error("[ORGANIZATION] Invalid organization of %v", account.Name)
+
+		result, _ := json.Marshal(map[string]string{"message": "Invalid organization name"})
+		return http.StatusBadRequest, result
+	}
+
+	if kind, _, err := module.NamespaceOwner(desc.Name); err != nil {
+		log.Error("[ORGANIZATION] Search namespace %v failed: %v", desc.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Search organization failed"})
+		return http.StatusInternalServerError, result
+	} else if kind != "" {
+		result, _ := json.Marshal(map[string]string{"message": "Name is already used by a user or an organization"})
+		return http.StatusConflict, result
+	}
+
+	if has, err := module.NamespaceHasRepositories(desc.Name); err != nil {
+		log.Error("[ORGANIZATION] Search repositories of %v failed: %v", desc.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Search repositories failed"})
+		return http.StatusInternalServerError, result
+	} else if has == true && !module.IsAdministrator(account.Name) {
+		log.Info("[ORGANIZATION] Organization %v refused to %v, the namespace has repositories", desc.Name, account.Name)
+
+		result, _ := json.Marshal(map[string]string{"message": "Namespace has repositories, only administrators create the organization"})
+		return http.StatusForbidden, result
+	}
+
+	o := new(models.Organization)
+	if err := o.Put(desc.Name, account.Name); err != nil {
+		log.Error("[ORGANIZATION] Save organization %v failed: %v", desc.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Save organization failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[ORGANIZATION] Organization %v created by %v", desc.Name, account.Name)
+
+	result, _ := json.Marshal(o)
+	return http.StatusCreated, result
+}
+
+// GetOrganizationHandler returns the organization with its teams to its members
+func GetOrganizationHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	o, status, result := getOrganization(ctx, log, account, false)
+	if o == nil {
+		return status, result
+	}
+
+	teams := []models.Team{}
+	for _, name := range o.Teams {
+		t := new(models.Team)
+		if has, _, err := t.Has(o.Name, name); err != nil {
+			log.Error("[ORGANIZATION] Get team %v/%v failed: %v", o.Name, name, err.Error())
+
+			result, _ := json.Marshal(map[string]string{"message": "Get team failed"})
+			return http.StatusInternalServerError, result
+		} else if has == true {
+			teams = append(teams, *t)
+		}
+	}
+
+	result, _ = json.Marshal(map[string]interface{}{"organization": o, "teams": teams})
+	return http.StatusOK, result
+}
+
+func PostTeamHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, body *middleware.RequestBody) (int, []byte) {
+	o, status, result := getOrganization(ctx, log, account, true)
+	if o == nil {
+		return status, result
+	}
+
+	var desc organizationDesc
+	if data, err := body.Bytes(); err != nil || json.Unmarshal(data, &desc) != nil || !module.ValidAccountName(desc.Name) {
+		log.Error("[ORGANIZATION] Invalid team of %v", o.Name)
+
+		result, _ := json.Marshal(map[string]string{"message": "Invalid team name"})
+		return http.StatusBadRequest, result
+	}
+
+	t := new(models.Team)
+	if has, _, err := t.Has(o.Name, desc.Name); err != nil {
+		log.Error("[ORGANIZATION] Get team %v/%v failed: %v", o.Name, desc.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get team failed"})
+		return http.StatusInternalServerError, result
+	} else if has == true {
+		result, _ := json.Marshal(map[string]string{"message": "Team already exists"})
+		return http.StatusConflict, result
+	}
+
+	if err := t.Put(o.Name, desc.Name); err != nil {
+		log.Error("[ORGANIZATION] Save team %v/%v failed: %v", o.Name, desc.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Save team failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[ORGANIZATION] Team %v/%v created by %v", o.Name, desc.Name, account.Name)
+
+	result, _ = json.Marshal(t)
+	return http.StatusCreated, result
+}
+
+func GetTeamHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	o, status, result := getOrganization(ctx, log, account, false)
+	if o == nil {
+		return status, result
+	}
+
+	t, status, result := getTeam(ctx, log, o)
+	if t == nil {
+		return status, result
+	}
+
+	result, _ = json.Marshal(t)
+	return http.StatusOK, result
+}
+
+func PutTeamMemberHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	o, status, result := getOrganization(ctx, log, account, true)
+	if o == nil {
+		return status, result
+	}
+
+	t, status, result := getTeam(ctx, log, o)
+	if t == nil {
+		return status, result
+	}
+
+	username := ctx.Params(":username")
+
+	u := new(models.User)
+	if has, _, err := u.Has(username); err != nil || has == false {
+		log.Error("[ORGANIZATION] User not found: %v", username)
+
+		result, _ := json.Marshal(map[string]string{"message": "User not found"})
+		return http.StatusNotFound, result
+	}
+
+	if err := t.PutMember(username); err != nil {
+		log.Error("[ORGANIZATION] Add %v to team %v/%v failed: %v", username, o.Name, t.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Add team member failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[ORGANIZATION] %v added to team %v/%v by %v", username, o.Name, t.Name, account.Name)
+
+	result, _ = json.Marshal(t)
+	return http.StatusOK, result
+}
+
+func DeleteTeamMemberHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	o, status, result := getOrganization(ctx, log, account, true)
+	if o == nil {
+		return status, result
+	}
+
+	t, status, result := getTeam(ctx, log, o)
+	if t == nil {
+		return status, result
+	}
+
+	username := ctx.Params(":username")
+
+	//an organization never loses all its owners
+	if t.Name == models.OwnersTeam && t.HasMember(username) && len(t.Members) == 1 {
+		result, _ := json.Marshal(map[string]string{"message": "The last owner can't be removed"})
+		return http.StatusBadRequest, result
+	}
+
+	if err := t.DeleteMember(username); err != nil {
+		log.Error("[ORGANIZATION] Remove %v from team %v/%v failed: %v", username, o.Name, t.Name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Remove team member failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[ORGANIZATION] %v removed from team %v/%v by %v", username, o.Name, t.Name, account.Name)
+
+	result, _ = json.Marshal(t)
+	return http.StatusOK, result
+}
+
+// GetNamespaceHandler returns the user or organization owning namespace
+func GetNamespaceHandler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+	namespace := ctx.Params(":namespace")
+
+	kind, name, err := module.NamespaceOwner(namespace)
+	if err != nil {
+		log.Error("[ORGANIZATION] Search namespace %v failed: %v", namespace, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Search namespace failed"})
+		return http.StatusInternalServerError, result
+	} else if kind == "" {
+		result, _ := json.Marshal(map[string]string{"message": "Namespace isn't owned"})
+		return http.StatusNotFound, result
+	}
+
+	result, _ := json.Marshal(map[string]string{"namespace": namespace, "kind": kind, "owner": name})
+	return http.StatusOK, result
+}
+
+// getOrganization returns the organization of request when account is its member, or its owner when owner is set,
+// otherwise the organization is nil with the error response.
+func getOrganization(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, owner bool) (*models.Organization, int, []byte) {
+	name := ctx.Params(":org")
+
+	if account.Name == "" {
+		result, _ := json.Marshal(map[string]string{"message": "Authentication required"})
+		return nil, http.StatusUnauthorized, result
+	} else if account.Limited() {
+		result, _ := json.Marshal(map[string]string{"message": "Access tokens and robots can't manage accounts"})
+		return nil, http.StatusForbidden, result
+	}
+
+	o := new(models.Organization)
+	if has, _, err := o.Has(name); err != nil {
+		log.Error("[ORGANIZATION] Get organization %v failed: %v", name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get organization failed"})
+		return nil, http.StatusInternalServerError, result
+	} else if has == false {
+		result, _ := json.Marshal(map[string]string{"message": "Organization not found"})
+		return nil, http.StatusNotFound, result
+	}
+
+	var allowed bool
+	var err error
+	if owner {
+		allowed, err = o.IsOwner(account.Name)
+	} else {
+		allowed, err = o.IsMember(account.Name)
+	}
+
+	if err != nil {
+		log.Error("[ORGANIZATION] Check member %v of %v failed: %v", account.Name, name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Check organization member failed"})
+		return nil, http.StatusInternalServerError, result
+	} else if allowed == false {
+		log.Info("[ORGANIZATION] %v denied by organization %v", account.Name, name)
+
+		result, _ := json.Marshal(map[string]string{"message": "Access to organization is denied"})
+		return nil, http.StatusForbidden, result
+	}
+
+	return o, http.StatusOK, nil
+}
+
+func getTeam(ctx *macaron.Context, log *logs.BeeLogger, o *models.Organization) (*models.Team, int, []byte) {
+	name := ctx.Params(":team")
+
+	t := new(models.Team)
+	if has, _, err := t.Has(o.Name, name); err != nil {
+		log.Error("[ORGANIZATION] Get team %v/%v failed: %v", o.Name, name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get team failed"})
+		return nil, http.StatusInternalServerError, result
+	} else if has == false {
+		result, _ := json.Marshal(map[string]string{"message": "Team not found"})
+		return nil, http.StatusNotFound, result
+	}
+
+	return t, http.StatusOK, nil
+}
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+)
+
+type visibilityDesc struct {
+	Private bool `json:"private"`
+}
+
+type permissionDesc struct {
+	Level string `json:"level"`
+}
+
+// GetPermissionsHandler returns the visibility and the grants of repository to its administrators
+func GetPermissionsHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	namespace, repository, level, status, result := getAdministered(ctx, log, account)
+	if level == "" {
+		return status, result
+	}
+
+	r := new(models.Repository)
+	if _, _, err := r.Has(namespace, repository); err != nil {
+		log.Error("[PERMISSION] Get repository %v/%v failed: %v", namespace, repository, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get repository failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	p := new(models.Permission)
+	if _, _, err := p.Has(namespace, repository); err != nil {
+		log.Error("[PERMISSION] Get permission of %v/%v failed: %v", namespace, repository, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get permission failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	result, _ = json.Marshal(map[string]interface{}{
+		"namespace":  namespace,
+		"repository": repository,
+		"private":    r.Privated,
+		"users":      p.Users,
+		"teams":      p.Teams,
+		"level":      level,
+	})
+	return http.StatusOK, result
+}
+
+func PutVisibilityHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, body *middleware.RequestBody) (int, []byte) {
+	namespace, repository, level, status, result := getAdministered(ctx, log, account)
+	if level == "" {
+		return status, result
+	}
+
+	var desc visibilityDesc
+	if data, err := body.Bytes(); err != nil || json.Unmarshal(data, &desc) != nil {
+		result, _ := json.Marshal(map[string]string{"message": "Invalid visibility"})
+		return http.StatusBadRequest, result
+	}
+
+	r := new(models.Repository)
+	if has, _, err := r.Has(namespace, repository); err != nil {
+		log.Error("[PERMISSION] Get repository %v/%v failed: %v", namespace, repository, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get repository failed"})
+		return http.StatusInternalServerError, result
+	} else if has == false {
+		result, _ := json.Marshal(map[string]string{"message": "Repository not found"})
+		return http.StatusNotFound, result
+	}
+
+	r.Privated = desc.Private
+	if err := r.Save(); err != nil {
+		log.Error("[PERMISSION] Save repository %v/%v failed: %v", namespace, repository, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Save repository failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[PERMISSION] %v/%v made private=%v by %v", namespace, repository, desc.Private, account.Name)
+
+	result, _ = json.Marshal(map[string]interface{}{"namespace": namespace, "repository": repository, "private": r.Privated})
+	return http.StatusOK, result
+}
+
+func PutUserPermissionHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, body *middleware.RequestBody) (int, []byte) {
+	namespace, repository, level, status, result := getAdministered(ctx, log, account)
+	if level == "" {
+		return status, result
+	}
+
+	granted, status, result := getPermissionLevel(body)
+	if granted == "" {
+		return status, result
+	}
+
+	username := ctx.Params(":username")
+
+	u := new(models.User)
+	if has, _, err := u.Has(username); err != nil || has == false {
+		log.Error("[PERMISSION] User not found: %v", username)
+
+		result, _ := json.Marshal(map[string]string{"message": "User not found"})
+		return http.StatusNotFound, result
+	}
+
+	return putPermission(log, account, namespace, repository, username, granted, func(p *models.Permission) error {
+		return p.PutUser(namespace, repository, username, granted)
+	})
+}
+
+func DeleteUserPermissionHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	namespace, repository, level, status, result := getAdministered(ctx, log, account)
+	if level == "" {
+		return status, result
+	}
+
+	username := ctx.Params(":username")
+
+	return putPermission(log, account, namespace, repository, username, "", func(p *models.Permission) error {
+		return p.PutUser(namespace, repository, username, "")
+	})
+}
+
+func PutTeamPermissionHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, body *middleware.RequestBody) (int, []byte) {
+	namespace, repository, level, status, result := getAdministered(ctx, log, account)
+	if level == "" {
+		return status, result
+	}
+
+	granted, status, result := getPermissionLevel(body)
+	if granted == "" {
+		return status, result
+	}
+
+	org, team := ctx.Params(":org"), ctx.Params(":team")
+
+	t := new(models.Team)
+	if has, _, err := t.Has(org, team); err != nil || has == false {
+		log.Error("[PERMISSION] Team not found: %v/%v", org, team)
+
+		result, _ := json.Marshal(map[string]string{"message": "Team not found"})
+		return http.StatusNotFound, result
+	}
+
+	return putPermission(log, account, namespace, repository, org+"/"+team, granted, func(p *models.Permission) error {
+		return p.PutTeam(namespace, repository, org, team, granted)
+	})
+}
+
+func DeleteTeamPermissionHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	namespace, repository, level, status, result := getAdministered(ctx, log, account)
+	if level == "" {
+		return status, result
+	}
+
+	org, team := ctx.Params(":org"), ctx.Params(":team")
+
+	return putPermission(log, account, namespace, repository, org+"/"+team, "", func(p *models.Permission) error {
+		return p.PutTeam(namespace, repository, org, team, "")
+	})
+}
+
+// getAdministered returns the repository of request with the permission level of account when it administers the repository,
+// otherwise the level is empty with the error response.
+func getAdministered(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (string, string, string, int, []byte) {
+	if account.Name == "" {
+		result, _ := json.Marshal(map[string]string{"message": "Authentication required"})
+		return "", "", "", http.StatusUnauthorized, result
+	} else if account.Limited() {
+		result, _ := json.Marshal(map[string]string{"message": "Access tokens and robots can't manage accounts"})
+		return "", "", "", http.StatusForbidden, result
+	}
+
+	name := ctx.Query("repository")
+	if name == "" {
+		result, _ := json.Marshal(map[string]string{"message": "Repository is required"})
+		return "", "", "", http.StatusBadRequest, result
+	}
+
+	namespace, repository := module.SplitRepositoryName(name)
+
+	level, err := module.RepositoryPermission(account.Name, namespace, repository)
+	if err != nil {
+		log.Error("[PERMISSION] Get permission of %v on %v failed: %v", account.Name, name, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get permission failed"})
+		return "", "", "", http.StatusInternalServerError, result
+	} else if !module.PermissionAllows(level, models.PermissionAdmin) {
+		log.Info("[PERMISSION] %v denied to administer %v", account.Name, name)
+
+		result, _ := json.Marshal(map[string]string{"message": "Access to repository permissions is denied"})
+		return "", "", "", http.StatusForbidden, result
+	}
+
+	return namespace, repository, level, http.StatusOK, nil
+}
+
+func getPermissionLevel(body *middleware.RequestBody) (string, int, []byte) {
+	var desc permissionDesc
+	if data, err := body.Bytes(); err != nil || json.Unmarshal(data, &desc) != nil || !module.ValidPermission(desc.Level) {
+		result, _ := json.Marshal(map[string]string{"message": "Level should be one of read, write and admin"})
+		return "", http.StatusBadRequest, result
+	}
+
+	return desc.Level, http.StatusOK, nil
+}
+
+// putPermission saves the grant of level to grantee made by put, an empty level is a revoke
+func putPermission(log *logs.BeeLogger, account *middleware.Account, namespace, repository, grantee, level string, put func(*models.Permission) error) (int, []byte) {
+	p := new(models.Permission)
+	if err := put(p); err != nil {
+		log.Error("[PERMISSION] Save permission of %v/%v failed: %v", namespace, repository, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Save permission failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	if level == "" {
+		log.Info("[PERMISSION] Permission of %v on %v/%v revoked by %v", grantee, namespace, repository, account.Name)
+	} else {
+		log.Info("[PERMISSION] %v granted to %v on %v/%v by %v", level, grantee, namespace, repository, account.Name)
+	}
+
+	result, _ := json.Marshal(p)
+	return http.StatusOK, result
+}
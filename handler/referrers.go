@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/dockyard/module/errcode"
+)
+
+func GetReferrersV2Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+	namespace := ctx.Params(":namespace")
+	repository := ctx.Params(":repository")
+	digest := ctx.Params(":digest")
+	artifactType := ctx.Query("artifactType")
+
+	if !module.IsDigest(digest) {
+		log.Error("[REGISTRY API V2] Invalid referrers subject digest: %v", digest)
+
+		return errcode.DigestInvalid.Response(digest)
+	}
+
+	r := new(models.Repository)
+	if has, _, err := r.Has(namespace, repository); err != nil || has == false {
+		log.Error("[REGISTRY API V2] Repository not found: %v", repository)
+
+		return errcode.NameUnknown.Response(fmt.Sprintf("%s/%s", namespace, repository))
+	}
+
+	referrers, err := module.GetReferrers(namespace, repository, digest, artifactType)
+	if err != nil {
+		log.Error("[REGISTRY API V2] List referrers of %v failed: %v", digest, err.Error())
+
+		return errcode.Unknown.Response("List referrers failed")
+	}
+
+	//an unknown subject has no referrers rather than not found
+	index := module.ManifestList{
+		SchemaVersion: 2,
+		MediaType:     module.OCIIndexMediaType,
+		Manifests:     referrers,
+	}
+
+	if artifactType != "" {
+		ctx.Resp.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	ctx.Resp.Header().Set("Content-Type", module.OCIIndexMediaType)
+
+	result, _ := json.Marshal(index)
+	return http.StatusOK, result
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/middleware/replication"
+	"github.com/containerops/dockyard/module"
+)
+
+func GetReplicationHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	if status, result := checkAdministrator(account); status != http.StatusOK {
+		return status, result
+	}
+
+	result, _ := json.Marshal(map[string][]replication.RuleStatus{"rules": replication.Status()})
+	return http.StatusOK, result
+}
+
+func GetReplicationRuleHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	if status, result := checkAdministrator(account); status != http.StatusOK {
+		return status, result
+	}
+
+	status, has := replication.GetStatus(ctx.Params(":rule"))
+	if has == false {
+		log.Error("[REPLICATION] Rule not found: %v", ctx.Params(":rule"))
+
+		result, _ := json.Marshal(map[string]string{"message": "Replication rule not found"})
+		return http.StatusNotFound, result
+	}
+
+	result, _ := json.Marshal(status)
+	return http.StatusOK, result
+}
+
+// checkAdministrator allows only administrators to read replication status, which has the peer registries and their errors
+func checkAdministrator(account *middleware.Account) (int, []byte) {
+	if account.Name == "" {
+		result, _ := json.Marshal(map[string]string{"message": "Authentication required"})
+		return http.StatusUnauthorized, result
+	} else if account.Limited() || !module.IsAdministrator(account.Name) {
+		result, _ := json.Marshal(map[string]string{"message": "Only administrators read replication status"})
+		return http.StatusForbidden, result
+	}
+
+	return http.StatusOK, nil
+}
@@ -9,10 +9,11 @@ import (
 	"github.com/astaxie/beego/logs"
 	"gopkg.in/macaron.v1"
 
+	"github.com/containerops/dockyard/middleware"
 	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
 	"github.com/containerops/wrench/db"
 	"github.com/containerops/wrench/setting"
-	"github.com/containerops/wrench/utils"
 )
 
 func PutTagV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
@@ -38,7 +39,7 @@ func PutTagV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 	return http.StatusOK, result
 }
 
-func PutRepositoryImagesV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func PutRepositoryImagesV1Handler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
 	namespace := ctx.Params(":namespace")
 	repository := ctx.Params(":repository")
 
@@ -51,12 +52,13 @@ func PutRepositoryImagesV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (in
 	}
 
 	if ctx.Req.Header.Get("X-Docker-Token") == "true" {
-		username, _, _ := utils.DecodeBasicAuth(ctx.Req.Header.Get("Authorization"))
-		token := fmt.Sprintf("Token signature=%v,repository=\"%v/%v\",access=%v",
-			utils.MD5(username),
-			namespace,
-			repository,
-			"write")
+		token, err := module.V1Token(account.Name, namespace, repository, []string{"pull", "push"})
+		if err != nil {
+			log.Error("[REGISTRY API V1] Issue token failed: %v", err.Error())
+
+			result, _ := json.Marshal(map[string]string{"message": "Issue token failed"})
+			return http.StatusInternalServerError, result
+		}
 
 		ctx.Resp.Header().Set("X-Docker-Token", token)
 		ctx.Resp.Header().Set("WWW-Authenticate", token)
@@ -66,7 +68,7 @@ func PutRepositoryImagesV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (in
 	return http.StatusNoContent, result
 }
 
-func GetRepositoryImagesV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+func GetRepositoryImagesV1Handler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
 	namespace := ctx.Params(":namespace")
 	repository := ctx.Params(":repository")
 
@@ -91,12 +93,13 @@ func GetRepositoryImagesV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (in
 		return http.StatusBadRequest, result
 	}
 
-	username, _, _ := utils.DecodeBasicAuth(ctx.Req.Header.Get("Authorization"))
-	token := fmt.Sprintf("Token signature=%v,repository=\"%v/%v\",access=%v",
-		utils.MD5(username),
-		namespace,
-		repository,
-		"read")
+	token, err := module.V1Token(account.Name, namespace, repository, []string{"pull"})
+	if err != nil {
+		log.Error("[REGISTRY API V1] Issue token failed: %v", err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Issue token failed"})
+		return http.StatusInternalServerError, result
+	}
 
 	ctx.Resp.Header().Set("X-Docker-Token", token)
 	ctx.Resp.Header().Set("WWW-Authenticate", token)
@@ -140,9 +143,7 @@ func GetTagV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
 	return http.StatusOK, result
 }
 
-func PutRepositoryV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
-	username, _, _ := utils.DecodeBasicAuth(ctx.Req.Header.Get("Authorization"))
-
+func PutRepositoryV1Handler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
 	namespace := ctx.Params(":namespace")
 	repository := ctx.Params(":repository")
 
@@ -162,11 +163,13 @@ func PutRepositoryV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []b
 	}
 
 	if ctx.Req.Header.Get("X-Docker-Token") == "true" {
-		token := fmt.Sprintf("Token signature=%v,repository=\"%v/%v\",access=%v",
-			utils.MD5(username),
-			namespace,
-			repository,
-			"write")
+		token, err := module.V1Token(account.Name, namespace, repository, []string{"pull", "push"})
+		if err != nil {
+			log.Error("[REGISTRY API V1] Issue token failed: %v", err.Error())
+
+			result, _ := json.Marshal(map[string]string{"message": "Issue token failed"})
+			return http.StatusInternalServerError, result
+		}
 
 		ctx.Resp.Header().Set("X-Docker-Token", token)
 		ctx.Resp.Header().Set("WWW-Authenticate", token)
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+)
+
+type robotDesc struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+}
+
+// GetRobotsHandler lists the robots of namespace to its owners, without their tokens
+func GetRobotsHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	namespace, status, result := getOwnedNamespace(ctx, log, account)
+	if namespace == "" {
+		return status, result
+	}
+
+	robots, err := models.ListRobots(namespace)
+	if err != nil {
+		log.Error("[ROBOT] List robots of %v failed: %v", namespace, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "List robots failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	for i := range robots {
+		robots[i].Token = ""
+	}
+
+	result, _ = json.Marshal(robots)
+	return http.StatusOK, result
+}
+
+// PostRobotHandler creates a robot of namespace, the token is only returned in the response
+// and it's used as the password of namespace+name.
+func PostRobotHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, body *middleware.RequestBody) (int, []byte) {
+	namespace, status, result := getOwnedNamespace(ctx, log, account)
+	if namespace == "" {
+		return status, result
+	}
+
+	var desc robotDesc
+	if data, err := body.Bytes(); err != nil || json.Unmarshal(data, &desc) != nil || !module.ValidAccountName(desc.Name) {
+		result, _ := json.Marshal(map[string]string{"message": "Invalid robot name"})
+		return http.StatusBadRequest, result
+	}
+
+	if !module.ValidPermission(desc.Level) {
+		result, _ := json.Marshal(map[string]string{"message": "Level should be one of read, write and admin"})
+		return http.StatusBadRequest, result
+	}
+
+	r := new(models.Robot)
+	if has, _, err := r.Has(namespace, desc.Name); err != nil {
+		log.Error("[ROBOT] Get robot %v of %v failed: %v", desc.Name, namespace, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get robot failed"})
+		return http.StatusInternalServerError, result
+	} else if has == true {
+		result, _ := json.Marshal(map[string]string{"message": "Robot already exists"})
+		return http.StatusConflict, result
+	}
+
+	token, err := r.Put(namespace, desc.Name, desc.Level, account.Name)
+	if err != nil {
+		log.Error("[ROBOT] Save robot %v of %v failed: %v", desc.Name, namespace, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Save robot failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[ROBOT] Robot %v created by %v", r.Username, account.Name)
+
+	r.Token = token
+	result, _ = json.Marshal(r)
+	return http.StatusCreated, result
+}
+
+func DeleteRobotHandler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	namespace, status, result := getOwnedNamespace(ctx, log, account)
+	if namespace == "" {
+		return status, result
+	}
+
+	name := ctx.Params(":robot")
+
+	r := new(models.Robot)
+	if has, _, err := r.Has(namespace, name); err != nil {
+		log.Error("[ROBOT] Get robot %v of %v failed: %v", name, namespace, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get robot failed"})
+		return http.StatusInternalServerError, result
+	} else if has == false {
+		result, _ := json.Marshal(map[string]string{"message": "Robot not found"})
+		return http.StatusNotFound, result
+	}
+
+	if err := r.Delete(); err != nil {
+		log.Error("[ROBOT] Revoke robot %v failed: %v", r.Username, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Revoke robot failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[ROBOT] Robot %v revoked by %v", r.Username, account.Name)
+
+	return http.StatusNoContent, []byte{}
+}
+
+// getOwnedNamespace returns the namespace of request when account is the user owning it or an owner of the organization owning it,
+// otherwise the namespace is empty with the error response.
+func getOwnedNamespace(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (string, int, []byte) {
+	namespace := ctx.Params(":namespace")
+
+	if account.Name == "" {
+		result, _ := json.Marshal(map[string]string{"message": "Authentication required"})
+		return "", http.StatusUnauthorized, result
+	} else if account.Limited() {
+		result, _ := json.Marshal(map[string]string{"message": "Access tokens and robots can't manage accounts"})
+		return "", http.StatusForbidden, result
+	}
+
+	kind, owner, err := module.NamespaceOwner(namespace)
+	if err != nil {
+		log.Error("[ROBOT] Search namespace %v failed: %v", namespace, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Search namespace failed"})
+		return "", http.StatusInternalServerError, result
+	} else if kind == "" || owner != namespace {
+		result, _ := json.Marshal(map[string]string{"message": "Namespace isn't owned"})
+		return "", http.StatusNotFound, result
+	}
+
+	allowed := owner == account.Name
+	if kind == module.NamespaceOrganization {
+		o := new(models.Organization)
+		if _, _, err := o.Has(owner); err != nil {
+			log.Error("[ROBOT] Get organization %v failed: %v", owner, err.Error())
+
+			result, _ := json.Marshal(map[string]string{"message": "Get organization failed"})
+			return "", http.StatusInternalServerError, result
+		}
+
+		if allowed, err = o.IsOwner(account.Name); err != nil {
+			log.Error("[ROBOT] Check owner %v of %v failed: %v", account.Name, owner, err.Error())
+
+			result, _ := json.Marshal(map[string]string{"message": "Check organization owner failed"})
+			return "", http.StatusInternalServerError, result
+		}
+	}
+
+	if allowed == false {
+		log.Info("[ROBOT] %v denied to manage robots of %v", account.Name, namespace)
+
+		result, _ := json.Marshal(map[string]string{"message": "Access to robots of namespace is denied"})
+		return "", http.StatusForbidden, result
+	}
+
+	return namespace, http.StatusOK, nil
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/dockyard/module/errcode"
+	"github.com/containerops/wrench/setting"
+)
+
+// GetTokenV2Handler issues a bearer token with the requested scopes granted to the account,
+// credentials which aren't accepted by the auth middleware are refused rather than treated as anonymous.
+func GetTokenV2Handler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	if _, _, has := ctx.Req.BasicAuth(); has == true && account.Name == "" {
+		log.Info("[REGISTRY API V2] Token request with invalid credentials")
+
+		ctx.Resp.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", setting.TokenRealm))
+		return errcode.Unauthorized.Response("invalid username or password")
+	}
+
+	requested := []module.Access{}
+	for _, scopes := range ctx.QueryStrings("scope") {
+		for _, scope := range strings.Fields(scopes) {
+			if access, err := module.ParseScope(scope); err != nil {
+				log.Warn("[REGISTRY API V2] %v", err.Error())
+			} else {
+				requested = append(requested, access)
+			}
+		}
+	}
+
+	granted, err := module.GrantAccess(account.Name, requested)
+	if err != nil {
+		log.Error("[REGISTRY API V2] Grant access of %v failed: %v", account.Name, err.Error())
+
+		return errcode.Unknown.Response("Grant access failed")
+	}
+
+	granted = module.LimitAccess(granted, account.Scopes)
+
+	token, claims, err := module.IssueToken(account.Name, setting.TokenService, granted)
+	if err != nil {
+		log.Error("[REGISTRY API V2] Issue token failed: %v", err.Error())
+
+		return errcode.Unknown.Response("Issue token failed")
+	}
+
+	ctx.Resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	result, _ := json.Marshal(map[string]interface{}{
+		"token":        token,
+		"access_token": token,
+		"expires_in":   claims.Expiration - claims.IssuedAt,
+		"issued_at":    time.Unix(claims.IssuedAt, 0).UTC().Format(time.RFC3339),
+	})
+
+	return http.StatusOK, result
+}
@@ -6,14 +6,152 @@ import (
 
 	"github.com/astaxie/beego/logs"
 	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
 )
 
-func GetUsersV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
+type userDesc struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+// GetUsersV1Handler is the login of docker, it succeeds when the credentials of request are accepted by the auth middleware
+func GetUsersV1Handler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account) (int, []byte) {
+	if account.Name == "" {
+		log.Info("[REGISTRY API V1] Login failed")
+
+		result, _ := json.Marshal(map[string]string{"message": "Wrong login/password, please try again"})
+		return http.StatusUnauthorized, result
+	}
+
 	result, _ := json.Marshal(map[string]string{})
 	return http.StatusOK, result
 }
 
-func PostUsersV1Handler(ctx *macaron.Context, log *logs.BeeLogger) (int, []byte) {
-	result, _ := json.Marshal(map[string]string{})
-	return http.StatusUnauthorized, result
+// PostUsersV1Handler signs up a user, docker logs in with GET when the user already exists
+func PostUsersV1Handler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, body *middleware.RequestBody) (int, []byte) {
+	var desc userDesc
+	if data, err := body.Bytes(); err != nil {
+		log.Error("[REGISTRY API V1] Read user body failed: %v", err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Read user body failed"})
+		return http.StatusBadRequest, result
+	} else if err := json.Unmarshal(data, &desc); err != nil {
+		log.Error("[REGISTRY API V1] Decode user failed: %v", err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Invalid user"})
+		return http.StatusBadRequest, result
+	}
+
+	//robots are never signed up, docker logs them in after the message
+	if _, _, is := models.SplitRobotName(desc.Username); is {
+		result, _ := json.Marshal("Username or email already exists")
+		return http.StatusBadRequest, result
+	}
+
+	if !module.ValidAccountName(desc.Username) || desc.Password == "" {
+		log.Error("[REGISTRY API V1] Invalid username or password of %v", desc.Username)
+
+		result, _ := json.Marshal(map[string]string{"message": "Invalid username or empty password"})
+		return http.StatusBadRequest, result
+	}
+
+	if kind, _, err := module.NamespaceOwner(desc.Username); err != nil {
+		log.Error("[REGISTRY API V1] Search namespace %v failed: %v", desc.Username, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Search user failed"})
+		return http.StatusInternalServerError, result
+	} else if kind != "" {
+		//docker logs in after the message
+		result, _ := json.Marshal("Username or email already exists")
+		return http.StatusBadRequest, result
+	}
+
+	if has, err := module.NamespaceHasRepositories(desc.Username); err != nil {
+		log.Error("[REGISTRY API V1] Search repositories of %v failed: %v", desc.Username, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Search repositories failed"})
+		return http.StatusInternalServerError, result
+	} else if has == true && !module.IsAdministrator(account.Name) {
+		log.Info("[REGISTRY API V1] Sign up %v refused, the namespace has repositories", desc.Username)
+
+		result, _ := json.Marshal(map[string]string{"message": "Namespace has repositories, only administrators sign it up"})
+		return http.StatusForbidden, result
+	}
+
+	u := new(models.User)
+	if err := u.Put(desc.Username, desc.Email, desc.Password); err != nil {
+		log.Error("[REGISTRY API V1] Save user %v failed: %v", desc.Username, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Save user failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[REGISTRY API V1] User %v signed up", desc.Username)
+
+	result, _ := json.Marshal("User created")
+	return http.StatusCreated, result
+}
+
+// PutUserV1Handler changes the password and email of the user who logs in
+func PutUserV1Handler(ctx *macaron.Context, log *logs.BeeLogger, account *middleware.Account, body *middleware.RequestBody) (int, []byte) {
+	username := ctx.Params(":username")
+
+	if account.Name == "" || account.Name != username {
+		log.Info("[REGISTRY API V1] Update user %v refused", username)
+
+		result, _ := json.Marshal(map[string]string{"message": "Wrong login/password, please try again"})
+		return http.StatusUnauthorized, result
+	} else if account.Limited() {
+		log.Info("[REGISTRY API V1] Update user %v with access token refused", username)
+
+		result, _ := json.Marshal(map[string]string{"message": "Access tokens and robots can't manage accounts"})
+		return http.StatusForbidden, result
+	}
+
+	var desc userDesc
+	if data, err := body.Bytes(); err != nil {
+		log.Error("[REGISTRY API V1] Read user body failed: %v", err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Read user body failed"})
+		return http.StatusBadRequest, result
+	} else if err := json.Unmarshal(data, &desc); err != nil {
+		log.Error("[REGISTRY API V1] Decode user failed: %v", err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Invalid user"})
+		return http.StatusBadRequest, result
+	}
+
+	u := new(models.User)
+	if has, _, err := u.Has(username); err != nil || has == false {
+		log.Error("[REGISTRY API V1] User not found: %v", username)
+
+		result, _ := json.Marshal(map[string]string{"message": "User not found"})
+		return http.StatusNotFound, result
+	}
+
+	if desc.Email != "" {
+		u.Email = desc.Email
+	}
+
+	var err error
+	if desc.Password != "" {
+		err = u.PutPassword(desc.Password)
+	} else {
+		err = u.Save()
+	}
+
+	if err != nil {
+		log.Error("[REGISTRY API V1] Update user %v failed: %v", username, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Update user failed"})
+		return http.StatusInternalServerError, result
+	}
+
+	log.Info("[REGISTRY API V1] User %v updated", username)
+
+	return http.StatusNoContent, []byte{}
 }
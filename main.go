@@ -7,7 +7,9 @@ import (
 	"github.com/codegangsta/cli"
 
 	"github.com/containerops/dockyard/cmd"
+	_ "github.com/containerops/dockyard/middleware/auth"
 	_ "github.com/containerops/dockyard/middleware/notifications"
+	_ "github.com/containerops/dockyard/middleware/replication"
 	"github.com/containerops/wrench/setting"
 )
 
@@ -30,6 +32,8 @@ func main() {
 
 	app.Commands = []cli.Command{
 		cmd.CmdWeb,
+		cmd.CmdMigrate,
+		cmd.CmdGC,
 	}
 
 	app.Flags = append(app.Flags, []cli.Flag{}...)
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/wrench/setting"
+)
+
+// checkAccess checks the permission on repository before V1 and ACI handlers, V2 requests are checked by their bearer tokens.
+// V1 images carry no repository, so they're only accessed with the tokens issued by V1 repository API for repositories listing them.
+// ACI images are taken as repositories of the default namespace. Nothing is checked when Dockyard runs standalone.
+func checkAccess() macaron.Handler {
+	return func(ctx *macaron.Context) {
+		if setting.Standalone == "true" {
+			return
+		}
+
+		path := ctx.Req.URL.Path
+		account := GetAccount(ctx)
+
+		switch {
+		case strings.HasPrefix(path, "/v1/repositories/"):
+			namespace, repository := ctx.Params(":namespace"), ctx.Params(":repository")
+			required := methodPermission(ctx.Req.Method)
+
+			//docker sends back the token issued by the repository API instead of its credentials
+			if claims, err := module.VerifyV1Token(ctx.Req.Header.Get("Authorization")); err == nil {
+				if claims.Allows("repository", fmt.Sprintf("%v/%v", namespace, repository), permissionAction(required)) {
+					account.Name = claims.Subject
+					return
+				}
+
+				deny(ctx, account, fmt.Sprintf("%v/%v", namespace, repository))
+				return
+			}
+
+			checkRepository(ctx, account, namespace, repository, required)
+		case strings.HasPrefix(path, "/v1/images/"):
+			checkImage(ctx, account, ctx.Params(":imageId"), methodPermission(ctx.Req.Method))
+		case strings.HasPrefix(path, "/ac-image/"):
+			checkRepository(ctx, account, setting.DefaultNamespace, strings.TrimSuffix(ctx.Params(":acname"), ".asc"), models.PermissionRead)
+		case ctx.Req.URL.Query().Get("ac-discovery") == "1":
+			checkRepository(ctx, account, setting.DefaultNamespace, ctx.Params(":imagename"), models.PermissionRead)
+		case strings.HasPrefix(path, "/ac-push/") && strings.HasSuffix(path, "/startupload"):
+			checkRepository(ctx, account, setting.DefaultNamespace, ctx.Params(":image"), models.PermissionWrite)
+		case strings.HasPrefix(path, "/ac-push") && path != "/ac-push/pubkeys.gpg":
+			//the rest of an ACI upload is checked against the user who started it by handlers
+			if account.Name == "" || !account.Allows("push") {
+				deny(ctx, account, "ACI push")
+			}
+		}
+	}
+}
+
+func checkRepository(ctx *macaron.Context, account *Account, namespace, repository, required string) {
+	level, err := module.RepositoryPermission(account.Name, namespace, repository)
+	if err != nil {
+		Log.Error("[ACCESS] Get permission of %v on %v/%v failed: %v", account.Name, namespace, repository, err.Error())
+
+		result, _ := json.Marshal(map[string]string{"message": "Get permission failed"})
+		ctx.Resp.WriteHeader(http.StatusInternalServerError)
+		ctx.Resp.Write(result)
+		return
+	}
+
+	//access tokens and robots are also limited by their scopes
+	if !module.PermissionAllows(level, required) || !account.Allows(permissionAction(required)) {
+		deny(ctx, account, fmt.Sprintf("%v/%v", namespace, repository))
+	}
+}
+
+// checkImage allows the V1 token to access the image when a repository of the token lists the image,
+// which docker pushes before the images, and the subject of token still has the permission on the repository
+func checkImage(ctx *macaron.Context, account *Account, imageId, required string) {
+	claims, err := module.VerifyV1Token(ctx.Req.Header.Get("Authorization"))
+	if err != nil {
+		deny(ctx, account, "image "+imageId)
+		return
+	}
+
+	for _, access := range claims.Access {
+		if access.Type != "repository" || !access.Allows(permissionAction(required)) {
+			continue
+		}
+
+		allowed, err := imagePermission(claims.Subject, access.Name, imageId, required)
+		if err != nil {
+			Log.Error("[ACCESS] Get permission of %v on image %v of %v failed: %v", claims.Subject, imageId, access.Name, err.Error())
+
+			result, _ := json.Marshal(map[string]string{"message": "Get permission failed"})
+			ctx.Resp.WriteHeader(http.StatusInternalServerError)
+			ctx.Resp.Write(result)
+			return
+		} else if allowed == true {
+			account.Name = claims.Subject
+			return
+		}
+	}
+
+	deny(ctx, account, "image "+imageId)
+}
+
+func imagePermission(username, name, imageId, required string) (bool, error) {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 {
+		return false, nil
+	}
+
+	r := new(models.Repository)
+	if has, err := r.HasImage(parts[0], parts[1], imageId); err != nil || has == false {
+		return false, err
+	}
+
+	level, err := module.RepositoryPermission(username, parts[0], parts[1])
+	if err != nil {
+		return false, err
+	}
+
+	return module.PermissionAllows(level, required), nil
+}
+
+// deny asks anonymous users for credentials and forbids authenticated users
+func deny(ctx *macaron.Context, account *Account, resource string) {
+	Log.Info("[ACCESS] %v %v of %v denied to %q", ctx.Req.Method, ctx.Req.URL.Path, resource, account.Name)
+
+	status := http.StatusForbidden
+	if account.Name == "" {
+		status = http.StatusUnauthorized
+		ctx.Resp.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", setting.Domains))
+	}
+
+	result, _ := json.Marshal(map[string]string{"message": "Access to " + resource + " is denied"})
+	ctx.Resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+	ctx.Resp.WriteHeader(status)
+	ctx.Resp.Write(result)
+}
+
+func methodPermission(method string) string {
+	switch method {
+	case "GET", "HEAD":
+		return models.PermissionRead
+	case "DELETE":
+		return models.PermissionAdmin
+	default:
+		return models.PermissionWrite
+	}
+}
+
+func permissionAction(level string) string {
+	switch level {
+	case models.PermissionRead:
+		return "pull"
+	case models.PermissionWrite:
+		return "push"
+	default:
+		return "delete"
+	}
+}
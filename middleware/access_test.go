@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/wrench/setting"
+)
+
+func Test_checkAccess(t *testing.T) {
+	initDB(t)
+
+	dir, err := ioutil.TempDir("", "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := module.LoadSigningKey(filepath.Join(dir, "signing.key")); err != nil {
+		t.Fatal(err)
+	}
+
+	Log = logs.NewLogger(10000)
+	setting.Standalone, setting.Domains = "false", "containerops.me"
+	setting.TokenService, setting.TokenIssuer = "containerops.me", "dockyard"
+	setting.TokenExpiration = time.Minute
+
+	//docker lists the images of repository before pushing them, only the user named after the namespace writes it
+	user := fmt.Sprintf("somebody%d", time.Now().UnixNano())
+	for repository, json := range map[string]string{"app": `[{"id":"abc"},{"id":"def","Tag":"latest"}]`, "other": `[{"id":"xyz"}]`} {
+		r := &models.Repository{Namespace: user, Repository: repository, JSON: json}
+		if err := r.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := macaron.New()
+	m.Use(setAccount())
+	m.Use(checkAccess())
+	m.Get("/v1/images/:imageId/json", func(account *Account) string { return account.Name })
+	m.Put("/v1/images/:imageId/json", func(account *Account) string { return account.Name })
+
+	serve := func(method, path, authorization string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		resp := httptest.NewRecorder()
+		m.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve("GET", "/v1/images/abc/json", "")
+	if resp.Code != http.StatusUnauthorized || resp.Header().Get("WWW-Authenticate") != `Basic realm="containerops.me"` {
+		t.Errorf("Expected anonymous image request challenged, got %v %v", resp.Code, resp.Header().Get("WWW-Authenticate"))
+	}
+
+	token := func(subject, repository string, actions ...string) string {
+		token, err := module.V1Token(subject, user, repository, actions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	read, write := token(user, "app", "pull"), token(user, "app", "pull", "push")
+
+	cases := []struct {
+		name, method, image, token string
+		expected                   int
+	}{
+		{"pull with read token", "GET", "abc", read, http.StatusOK},
+		{"push with read token", "PUT", "abc", read, http.StatusUnauthorized},
+		{"push with write token", "PUT", "def", write, http.StatusOK},
+		{"image not listed by repository", "GET", "xyz", write, http.StatusUnauthorized},
+		{"image listed by another repository", "GET", "abc", token(user, "other", "pull", "push"), http.StatusUnauthorized},
+		{"pull by somebody else of public repository", "GET", "abc", token("somebody", "app", "pull"), http.StatusOK},
+		{"push by somebody else without permission", "PUT", "abc", token("somebody", "app", "pull", "push"), http.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		if resp := serve(c.method, "/v1/images/"+c.image+"/json", c.token); resp.Code != c.expected {
+			t.Errorf("%v: expected %v, got %v %v", c.name, c.expected, resp.Code, resp.Body.String())
+		}
+	}
+
+	if resp := serve("GET", "/v1/images/abc/json", read); resp.Body.String() != user {
+		t.Errorf("Expected image pulled by %v with token, got %v", user, resp.Body.String())
+	}
+
+	setting.Standalone = "true"
+	if resp := serve("PUT", "/v1/images/abc/json", ""); resp.Code != http.StatusOK {
+		t.Errorf("Expected nothing checked standalone, got %v", resp.Code)
+	}
+	setting.Standalone = "false"
+}
+
+func Test_checkAccessScopes(t *testing.T) {
+	Log = logs.NewLogger(10000)
+	setting.Standalone = "false"
+
+	scopes := []string{}
+	m := macaron.New()
+	m.Use(setAccount())
+	m.Use(func(ctx *macaron.Context) {
+		account := GetAccount(ctx)
+		account.Name, account.Scopes = "somebody", scopes
+	})
+	m.Use(checkAccess())
+	m.Put("/ac-push/manifest/:num", func(account *Account) string { return account.Name })
+
+	serve := func() int {
+		req, _ := http.NewRequest("PUT", "/ac-push/manifest/1", nil)
+		resp := httptest.NewRecorder()
+		m.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	scopes = []string{"pull"}
+	if code := serve(); code != http.StatusForbidden {
+		t.Errorf("Expected ACI push refused to token with pull scope, got %v", code)
+	}
+
+	scopes = []string{"pull", "push"}
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("Expected ACI push allowed to token with push scope, got %v", code)
+	}
+
+	scopes = nil
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("Expected ACI push allowed to password, got %v", code)
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"reflect"
+
+	"gopkg.in/macaron.v1"
+)
+
+// Account is the user who sends the request, the name is set by the auth middleware which verifies
+// the credentials of request, or from the subject of bearer token. An empty name is anonymous.
+// Access tokens and robots are limited to the actions in scopes, which are nil for passwords and bearer tokens.
+type Account struct {
+	Name   string
+	Scopes []string
+}
+
+// Allows reports whether the credentials of account are allowed action on repositories
+func (a *Account) Allows(action string) bool {
+	if a.Scopes == nil {
+		return true
+	}
+
+	for _, scope := range a.Scopes {
+		if scope == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Limited reports whether account logs in with an access token or as a robot, which never manage accounts
+func (a *Account) Limited() bool {
+	return a.Scopes != nil
+}
+
+// GetAccount returns the Account mapped to the request context, and maps an anonymous one when it's missing
+func GetAccount(ctx *macaron.Context) *Account {
+	if v := ctx.GetVal(reflect.TypeOf((*Account)(nil))); v.IsValid() {
+		return v.Interface().(*Account)
+	}
+
+	account := &Account{}
+	ctx.Map(account)
+
+	return account
+}
+
+func setAccount() macaron.Handler {
+	return func(ctx *macaron.Context) {
+		ctx.Map(&Account{})
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"fmt"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/wrench/setting"
+)
+
+// authenticator verifies the password of a user against its backend
+type authenticator interface {
+	authenticate(username, password string) (bool, error)
+}
+
+// auth is the middleware of an authenticator, the one named in the auth block of config.json is selected, e.g.
+// {"auth": {"htpasswd": {"path": "conf/htpasswd"}}}
+type auth struct {
+	name          string
+	new           func(desc setting.AuthorDesc) (authenticator, error)
+	authenticator authenticator
+}
+
+func register(name string, new func(desc setting.AuthorDesc) (authenticator, error)) {
+	middleware.Register(name, middleware.HandlerInterface(&auth{name: name, new: new}))
+}
+
+func (a *auth) InitFunc() error {
+	authenticator, err := a.new(setting.JSONConfCtx.Authors[a.name])
+	if err != nil {
+		return err
+	}
+
+	a.authenticator = authenticator
+
+	return nil
+}
+
+// Handler sets the account of request when its Basic credentials are verified,
+// requests with credentials refused are left anonymous for the handlers to refuse.
+// Robots and access tokens are kept in Dockyard database and accepted whichever authenticator is selected,
+// robots log in with their tokens and users log in with their access tokens instead of their passwords.
+func (a *auth) Handler(ctx *macaron.Context) {
+	username, password, has := ctx.Req.BasicAuth()
+	if has == false || username == "" || password == "" {
+		return
+	}
+
+	var ok bool
+	var scopes []string
+	var err error
+	if namespace, name, is := models.SplitRobotName(username); is {
+		ok, scopes, err = authenticateRobot(namespace, name, password)
+	} else if ok, err = a.authenticator.authenticate(username, password); err == nil && ok == false {
+		ok, scopes, err = authenticateAccessToken(username, password)
+	}
+
+	if err != nil {
+		middleware.Log.Error("[AUTH] Authenticate %v by %v failed: %v", username, a.name, err.Error())
+	} else if ok == false {
+		middleware.Log.Info("[AUTH] Invalid credentials of %v", username)
+	} else {
+		account := middleware.GetAccount(ctx)
+		account.Name, account.Scopes = username, scopes
+	}
+}
+
+// authenticateRobot verifies the token of robot, which is limited to the actions of its level
+func authenticateRobot(namespace, name, token string) (bool, []string, error) {
+	robot := new(models.Robot)
+	if has, _, err := robot.Has(namespace, name); err != nil || has == false || !robot.Verify(token) {
+		return false, nil, err
+	}
+
+	return true, module.PermissionActions(robot.Level), robot.Used()
+}
+
+// authenticateAccessToken verifies the access token of user, which is limited to its scopes
+func authenticateAccessToken(username, token string) (bool, []string, error) {
+	t := new(models.AccessToken)
+	if ok, err := t.Verify(username, token); err != nil || ok == false {
+		return false, nil, err
+	}
+
+	return true, t.Scopes, t.Used()
+}
+
+// option returns the string option of authenticator, required options must not be empty
+func option(desc setting.AuthorDesc, key string, required bool) (string, error) {
+	value, _ := desc[key].(string)
+	if value == "" && required {
+		return "", fmt.Errorf("Auth option %v is required", key)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/wrench/db"
+	"github.com/containerops/wrench/setting"
+)
+
+var dbErr error
+
+// TestMain connects the redis of DOCKYARD_TEST_REDIS or localhost:6379 with db 15, robots and access tokens
+// are looked up in it whichever authenticator is tested, and refused when redis isn't available.
+func TestMain(m *testing.M) {
+	addr := os.Getenv("DOCKYARD_TEST_REDIS")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	dbErr = db.InitDB(addr, "", 15)
+
+	os.Exit(m.Run())
+}
+
+// initDB skips tests saving robots and access tokens without redis
+func initDB(t *testing.T) {
+	if dbErr != nil {
+		t.Skipf("Redis isn't available: %v", dbErr)
+	}
+}
+
+func Test_authRobotsAndAccessTokens(t *testing.T) {
+	initDB(t)
+
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	user := fmt.Sprintf("somebody%d", time.Now().UnixNano())
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "htpasswd")
+	if err := ioutil.WriteFile(path, []byte(user+":"+string(hash)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	middleware.Log = logs.NewLogger(10000)
+	setting.JSONConfCtx.Authors = setting.AuthorsCtx{"htpasswd": setting.AuthorDesc{"path": path}}
+	if err := middleware.Initfunc(); err != nil {
+		t.Fatal(err)
+	}
+
+	robot := new(models.Robot)
+	robotToken, err := robot.Put(user, "ci", models.PermissionRead, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accessToken, err := new(models.AccessToken).Put(user, "jenkins", []string{"pull", "push"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := macaron.New()
+	m.Use(middleware.Handlefunc())
+	m.Get("/v2/token", func(ctx *macaron.Context) string {
+		account := middleware.GetAccount(ctx)
+		return fmt.Sprintf("%v %v", account.Name, strings.Join(account.Scopes, ","))
+	})
+
+	cases := []struct {
+		username, password, expected string
+	}{
+		{user, "secret", user + " "},
+		{user, accessToken, user + " pull,push"},
+		{robot.Username, robotToken, robot.Username + " pull"},
+		{robot.Username, accessToken, " "},
+		{"nobody", accessToken, " "},
+		{user, robotToken, " "},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/v2/token", nil)
+		req.SetBasicAuth(c.username, c.password)
+		resp := httptest.NewRecorder()
+		m.ServeHTTP(resp, req)
+
+		if resp.Body.String() != c.expected {
+			t.Errorf("%v: expected account %q, got %q", c.username, c.expected, resp.Body.String())
+		}
+	}
+
+	if err := robot.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", "/v2/token", nil)
+	req.SetBasicAuth(robot.Username, robotToken)
+	resp := httptest.NewRecorder()
+	m.ServeHTTP(resp, req)
+
+	if resp.Body.String() != " " {
+		t.Errorf("Expected revoked robot refused, got %q", resp.Body.String())
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/containerops/wrench/setting"
+)
+
+func init() {
+	register("htpasswd", newHtpasswd)
+}
+
+// htpasswd verifies users of an Apache htpasswd file created with `htpasswd -B`,
+// the file is loaded again when it's modified.
+type htpasswd struct {
+	path string
+
+	mu       sync.Mutex
+	modified time.Time
+	users    map[string][]byte
+}
+
+func newHtpasswd(desc setting.AuthorDesc) (authenticator, error) {
+	path, err := option(desc, "path", true)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &htpasswd{path: path}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *htpasswd) authenticate(username, password string) (bool, error) {
+	if err := h.load(); err != nil {
+		return false, err
+	}
+
+	h.mu.Lock()
+	hash, has := h.users[username]
+	h.mu.Unlock()
+
+	if has == false {
+		return false, nil
+	}
+
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil, nil
+}
+
+func (h *htpasswd) load() error {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.users != nil && info.ModTime().Equal(h.modified) {
+		return nil
+	}
+
+	fd, err := os.Open(h.path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	users := map[string][]byte{}
+	scanner := bufio.NewScanner(fd)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("Invalid htpasswd entry at line %v", line)
+		}
+
+		//only bcrypt is secure enough, the others are refused rather than ignored
+		if _, err := bcrypt.Cost([]byte(parts[1])); err != nil {
+			return fmt.Errorf("Password of %v isn't hashed by bcrypt, create it with htpasswd -B", parts[0])
+		}
+
+		users[parts[0]] = []byte(parts[1])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	h.users, h.modified = users, info.ModTime()
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/astaxie/beego/logs"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/wrench/setting"
+)
+
+func Test_htpasswd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "htpasswd")
+	if err := ioutil.WriteFile(path, []byte("# users\nsomebody:"+string(hash)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	middleware.Log = logs.NewLogger(10000)
+	setting.JSONConfCtx.Authors = setting.AuthorsCtx{"htpasswd": setting.AuthorDesc{"path": path}}
+	if err := middleware.Initfunc(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := macaron.New()
+	m.Use(middleware.Handlefunc())
+	m.Get("/v2/token", func(ctx *macaron.Context) string {
+		return middleware.GetAccount(ctx).Name
+	})
+
+	cases := []struct {
+		username, password, expected string
+	}{
+		{"somebody", "secret", "somebody"},
+		{"somebody", "wrong", ""},
+		{"nobody", "secret", ""},
+		{"somebody", "", ""},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/v2/token", nil)
+		req.SetBasicAuth(c.username, c.password)
+		resp := httptest.NewRecorder()
+		m.ServeHTTP(resp, req)
+
+		if resp.Body.String() != c.expected {
+			t.Errorf("%v:%v: expected account %q, got %q", c.username, c.password, c.expected, resp.Body.String())
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte("somebody:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newHtpasswd(setting.AuthorDesc{"path": path}); err == nil {
+		t.Errorf("Expected password not hashed by bcrypt refused")
+	}
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/containerops/wrench/setting"
+)
+
+const ldapTimeout = 10 * time.Second
+
+func init() {
+	register("ldap", newLDAP)
+}
+
+// ldapBind verifies users by binding to LDAP server with their DN and password,
+// the DN is searched under base DN by filter with the service account, or anonymously when bind DN is empty.
+type ldapBind struct {
+	url          string
+	bindDN       string
+	bindPassword string
+	baseDN       string
+	filter       string
+	startTLS     bool
+}
+
+func newLDAP(desc setting.AuthorDesc) (authenticator, error) {
+	l := &ldapBind{filter: "(uid=%s)"}
+
+	var err error
+	if l.url, err = option(desc, "url", true); err != nil {
+		return nil, err
+	}
+
+	if l.baseDN, err = option(desc, "basedn", true); err != nil {
+		return nil, err
+	}
+
+	l.bindDN, _ = option(desc, "binddn", false)
+	l.bindPassword, _ = option(desc, "bindpassword", false)
+	l.startTLS, _ = desc["starttls"].(bool)
+
+	if filter, _ := option(desc, "filter", false); filter != "" {
+		l.filter = filter
+	}
+
+	return l, nil
+}
+
+func (l *ldapBind) authenticate(username, password string) (bool, error) {
+	//binding with an empty password is an unauthenticated bind which always succeeds
+	if password == "" {
+		return false, nil
+	}
+
+	conn, err := ldap.DialURL(l.url)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	conn.SetTimeout(ldapTimeout)
+
+	if l.startTLS {
+		u, err := url.Parse(l.url)
+		if err != nil {
+			return false, err
+		}
+
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			return false, err
+		}
+	}
+
+	if l.bindDN != "" {
+		if err := conn.Bind(l.bindDN, l.bindPassword); err != nil {
+			return false, fmt.Errorf("Bind service account failed: %v", err.Error())
+		}
+	}
+
+	request := ldap.NewSearchRequest(l.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout/time.Second), false,
+		fmt.Sprintf(l.filter, ldap.EscapeFilter(username)), []string{"dn"}, nil)
+
+	result, err := conn.Search(request)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return false, fmt.Errorf("More than one entry of %v found", username)
+	} else if err != nil {
+		return false, err
+	} else if len(result.Entries) != 1 {
+		return false, nil
+	}
+
+	if err := conn.Bind(result.Entries[0].DN, password); ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"net"
+	"testing"
+
+	"github.com/go-asn1-ber/asn1-ber"
+
+	"github.com/containerops/wrench/setting"
+)
+
+// ldapStandIn is an in-process LDAP server which only answers simple binds and searches by uid
+type ldapStandIn struct {
+	listener  net.Listener
+	passwords map[string]string //dn -> password
+	uids      map[string]string //uid -> dn
+}
+
+func newLDAPStandIn(t *testing.T) *ldapStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &ldapStandIn{
+		listener: listener,
+		passwords: map[string]string{
+			"cn=admin,dc=containerops,dc=me":               "admin",
+			"uid=somebody,ou=people,dc=containerops,dc=me": "secret",
+		},
+		uids: map[string]string{
+			"somebody": "uid=somebody,ou=people,dc=containerops,dc=me",
+		},
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *ldapStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case 0: //bind request: version, name, simple password
+			code := int64(49)
+			if password, has := s.passwords[op.Children[1].Data.String()]; has && password == op.Children[2].Data.String() {
+				code = 0
+			}
+			conn.Write(ldapResult(id, 1, code).Bytes())
+		case 3: //search request with an equality filter of uid
+			filter := op.Children[6]
+			if filter.Tag == 3 && filter.Children[0].Data.String() == "uid" {
+				if dn, has := s.uids[filter.Children[1].Data.String()]; has {
+					entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+					entry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+					result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 4, nil, "")
+					result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, ""))
+					result.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, ""))
+					entry.AppendChild(result)
+					conn.Write(entry.Bytes())
+				}
+			}
+			conn.Write(ldapResult(id, 5, 0).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func ldapResult(id int64, tag ber.Tag, code int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	packet.AppendChild(result)
+
+	return packet
+}
+
+func Test_ldap(t *testing.T) {
+	s := newLDAPStandIn(t)
+	defer s.listener.Close()
+
+	l, err := newLDAP(setting.AuthorDesc{
+		"url":          "ldap://" + s.listener.Addr().String(),
+		"binddn":       "cn=admin,dc=containerops,dc=me",
+		"bindpassword": "admin",
+		"basedn":       "dc=containerops,dc=me",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		username, password string
+		expected           bool
+	}{
+		{"somebody", "secret", true},
+		{"somebody", "wrong", false},
+		{"somebody", "", false},
+		{"nobody", "secret", false},
+		{"*", "secret", false},
+	}
+
+	for _, c := range cases {
+		if ok, err := l.authenticate(c.username, c.password); err != nil {
+			t.Errorf("%v:%v: %v", c.username, c.password, err)
+		} else if ok != c.expected {
+			t.Errorf("%v:%v: expected %v, got %v", c.username, c.password, c.expected, ok)
+		}
+	}
+
+	if _, err := newLDAP(setting.AuthorDesc{"basedn": "dc=containerops,dc=me"}); err == nil {
+		t.Errorf("Expected url required")
+	}
+}
@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/wrench/setting"
+)
+
+func init() {
+	register("redis", newRedis)
+}
+
+// redisStore verifies users signed up in Dockyard database
+type redisStore struct{}
+
+func newRedis(desc setting.AuthorDesc) (authenticator, error) {
+	return &redisStore{}, nil
+}
+
+func (r *redisStore) authenticate(username, password string) (bool, error) {
+	u := new(models.User)
+	if has, _, err := u.Has(username); err != nil || has == false {
+		return false, err
+	}
+
+	return u.Verify(password), nil
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"gopkg.in/macaron.v1"
+)
+
+// RequestBody buffers the body of a request at the first read, so middlewares and handlers
+// of the same request could all read it without sharing any global state.
+type RequestBody struct {
+	req  *http.Request
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func NewRequestBody(req *http.Request) *RequestBody {
+	return &RequestBody{req: req}
+}
+
+// Bytes returns the buffered body, the request body is replaced with the buffer so it could still be read as usual
+func (b *RequestBody) Bytes() ([]byte, error) {
+	b.once.Do(func() {
+		if b.req.Body == nil {
+			return
+		}
+
+		b.data, b.err = ioutil.ReadAll(b.req.Body)
+		b.req.Body.Close()
+		b.req.Body = ioutil.NopCloser(bytes.NewReader(b.data))
+	})
+
+	return b.data, b.err
+}
+
+// GetRequestBody returns the RequestBody mapped to the request context, and maps one when it's missing
+func GetRequestBody(ctx *macaron.Context) *RequestBody {
+	if v := ctx.GetVal(reflect.TypeOf((*RequestBody)(nil))); v.IsValid() {
+		return v.Interface().(*RequestBody)
+	}
+
+	body := NewRequestBody(ctx.Req.Request)
+	ctx.Map(body)
+
+	return body
+}
+
+func setRequestBody() macaron.Handler {
+	return func(ctx *macaron.Context) {
+		ctx.Map(NewRequestBody(ctx.Req.Request))
+	}
+}
@@ -0,0 +1,189 @@
+package middleware_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	_ "github.com/containerops/dockyard/middleware/notifications"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/dockyard/router"
+	"github.com/containerops/wrench/db"
+	"github.com/containerops/wrench/setting"
+)
+
+// newChain returns the middlewares Dockyard serves requests with, logging to a file in dir
+func newChain(t *testing.T, dir string) *macaron.Macaron {
+	setting.RunMode, setting.LogPath, setting.Standalone = "test", filepath.Join(dir, "dockyard.log"), "true"
+
+	if err := middleware.Initfunc(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := macaron.New()
+	middleware.SetMiddlewares(m)
+
+	return m
+}
+
+// Test_requestBody pushes different bodies concurrently, a middleware and the handler both read the body
+// and every handler must see its own request, run with -race to catch shared state.
+func Test_requestBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	setting.JSONConfCtx.Notifications = setting.NotificationsCtx{}
+
+	m := newChain(t, dir)
+	m.Use(func(ctx *macaron.Context) {
+		if _, err := middleware.GetRequestBody(ctx).Bytes(); err != nil {
+			t.Error(err)
+		}
+	})
+	m.Put("/v2/:name/manifests/:tag", func(ctx *macaron.Context, body *middleware.RequestBody) (int, []byte) {
+		data, err := body.Bytes()
+		if err != nil {
+			return http.StatusBadRequest, []byte(err.Error())
+		}
+
+		//the request body is still readable after it's buffered
+		raw, _ := ioutil.ReadAll(ctx.Req.Request.Body)
+		if string(raw) != string(data) {
+			return http.StatusBadRequest, []byte("request body differs from buffered body")
+		}
+
+		return http.StatusCreated, data
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			manifest := fmt.Sprintf(`{"schemaVersion":2,"tag":"%v"}`, i)
+			req, _ := http.NewRequest("PUT", fmt.Sprintf("/v2/library/busybox/manifests/%v", i), strings.NewReader(manifest))
+			req.RequestURI = req.URL.Path
+			resp := httptest.NewRecorder()
+			m.ServeHTTP(resp, req)
+
+			if resp.Code != http.StatusCreated || resp.Body.String() != manifest {
+				t.Errorf("Expected manifest %v, got %v: %v", manifest, resp.Code, resp.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Test_requestBodyManifest pushes a manifest through the middlewares and routers of Dockyard,
+// the notifications middleware and PutManifestsV2Handler must both read the whole body.
+func Test_requestBodyManifest(t *testing.T) {
+	addr := os.Getenv("DOCKYARD_TEST_REDIS")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	if err := db.InitDB(addr, "", 15); err != nil {
+		t.Skipf("Redis isn't available: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var mu sync.Mutex
+	var envelopes []string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		envelopes = append(envelopes, string(data))
+		mu.Unlock()
+	}))
+	defer endpoint.Close()
+
+	setting.JSONConfCtx.Notifications = setting.NotificationsCtx{
+		Name:      "notifications",
+		Endpoints: []setting.EndpointDesc{{Name: "test", URL: endpoint.URL, Timeout: 1000, Threshold: 1, Backoff: 1}},
+	}
+	defer func() { setting.JSONConfCtx.Notifications = setting.NotificationsCtx{} }()
+
+	m := newChain(t, dir)
+	router.SetRouters(m)
+
+	config, layer := fmt.Sprintf("%x", sha256.Sum256([]byte("config"))), fmt.Sprintf("%x", sha256.Sum256([]byte("layer")))
+	for _, tarsum := range []string{config, layer} {
+		i := &models.Image{Path: filepath.Join(dir, tarsum)}
+		if err := i.PutTarsum(tarsum); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest, _ := json.Marshal(module.ManifestV2{
+		SchemaVersion: 2,
+		MediaType:     module.ManifestV2MediaType,
+		Config:        module.Descriptor{MediaType: module.ImageConfigMediaType, Digest: "sha256:" + config},
+		Layers:        []module.Descriptor{{MediaType: module.LayerMediaType, Digest: "sha256:" + layer}},
+	})
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+
+	repository := fmt.Sprintf("body%d", time.Now().UnixNano())
+
+	req, _ := http.NewRequest("PUT", "/v2/library/"+repository+"/manifests/latest", strings.NewReader(string(manifest)))
+	req.Header.Set("Content-Type", module.ManifestV2MediaType)
+	req.RequestURI = req.URL.Path
+	resp := httptest.NewRecorder()
+	m.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusAccepted || resp.Header().Get("Docker-Content-Digest") != digest {
+		t.Fatalf("Expected manifest %v saved, got %v %v: %v", digest, resp.Code, resp.Header().Get("Docker-Content-Digest"), resp.Body.String())
+	}
+
+	if has, data, _, err := module.GetManifest("library", repository, "latest"); err != nil || has == false || string(data) != string(manifest) {
+		t.Errorf("Expected handler saved the whole body, got %v %q %v", has, data, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(envelopes) != 1 {
+		t.Fatalf("Expected one event sent for manifest push, got %v", len(envelopes))
+	}
+
+	var envelope struct {
+		Events []struct {
+			Action string `json:"action"`
+			Target struct {
+				Digest     string `json:"digest"`
+				Length     int64  `json:"length"`
+				Repository string `json:"repository"`
+			} `json:"target"`
+		} `json:"events"`
+	}
+	if err := json.Unmarshal([]byte(envelopes[0]), &envelope); err != nil || len(envelope.Events) != 1 {
+		t.Fatalf("Expected event envelope, got %v %v", envelopes[0], err)
+	}
+
+	event := envelope.Events[0]
+	if event.Action != "push" || event.Target.Digest != digest || event.Target.Length != int64(len(manifest)) || event.Target.Repository != "library/"+repository {
+		t.Errorf("Expected push event of %v with length %v, got %+v", digest, len(manifest), event)
+	}
+}
@@ -24,8 +24,22 @@ func SetMiddlewares(m *macaron.Macaron) {
 	//Set the response header info
 	m.Use(setRespHeaders())
 
+	//Route V2 API by repository name of any depth
+	m.Before(rewriteRepositoryName)
+	m.Use(setRepositoryName())
+
+	//Set the account of request, then check bearer tokens of V2 API
+	m.Use(setAccount())
+	m.Use(checkToken())
+
+	//Set request scoped body buffer shared by middlewares and handlers
+	m.Use(setRequestBody())
+
 	m.Use(Handlefunc())
 
+	//Check the permission of V1 and ACI requests once the auth middleware sets the account
+	m.Use(checkAccess())
+
 	//Set recovery handler to returns a middleware that recovers from any panics
 	m.Use(macaron.Recovery())
 }
@@ -35,6 +49,11 @@ type HandlerInterface interface {
 	Handler(ctx *macaron.Context)
 }
 
+// AfterInterface is implemented by middlewares which act on the response once the handler has written it
+type AfterInterface interface {
+	After(ctx *macaron.Context)
+}
+
 var Middleware map[string]HandlerInterface = map[string]HandlerInterface{}
 
 func Register(name string, handler HandlerInterface) error {
@@ -48,8 +67,17 @@ func Register(name string, handler HandlerInterface) error {
 	return nil
 }
 
+// authName returns the authenticator selected by the auth block of config.json, users signed up are authenticated by default
+func authName() string {
+	if name := setting.JSONConfCtx.Authors.Name(); name != "" {
+		return name
+	}
+
+	return "redis"
+}
+
 func Initfunc() error {
-	var namespace []string = []string{setting.JSONConfCtx.Authors.Name(), setting.JSONConfCtx.Notifications.Name}
+	var namespace []string = []string{authName(), setting.JSONConfCtx.Notifications.Name, setting.JSONConfCtx.Replication.Name}
 
 	for _, name := range namespace {
 		if handlerinterface, existed := Middleware[name]; existed {
@@ -64,11 +92,24 @@ func Initfunc() error {
 
 func Handlefunc() macaron.Handler {
 	return func(ctx *macaron.Context) {
-		var namespace []string = []string{setting.JSONConfCtx.Authors.Name(), setting.JSONConfCtx.Notifications.Name}
+		var namespace []string = []string{authName(), setting.JSONConfCtx.Notifications.Name, setting.JSONConfCtx.Replication.Name}
 
 		for _, name := range namespace {
 			if handlerinterface, existed := Middleware[name]; existed {
 				handlerinterface.Handler(ctx)
+
+				//the request is answered by the middleware, e.g. refused by the auth middleware
+				if ctx.Written() {
+					return
+				}
+			}
+		}
+
+		ctx.Next()
+
+		for _, name := range namespace {
+			if after, ok := Middleware[name].(AfterInterface); ok {
+				after.After(ctx)
 			}
 		}
 	}
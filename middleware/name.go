@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/module"
+)
+
+// V2 API paths with a repository name of any depth before the resource, e.g. /v2/team/project/service/manifests/latest
+var repositoryPathRegexp = regexp.MustCompile(`^/v2/(` + module.RepositoryNameRegexp.String() + `)/((?:blobs|manifests|tags|referrers)/.*)$`)
+
+// rewriteRepositoryName escapes the repository name of V2 API path into one segment before routing,
+// because the router only matches a parameter within a single path segment.
+func rewriteRepositoryName(rw http.ResponseWriter, req *http.Request) bool {
+	if match := repositoryPathRegexp.FindStringSubmatch(req.URL.Path); match != nil {
+		req.URL.Path = "/v2/" + url.PathEscape(match[1]) + "/" + match[2]
+		req.URL.RawPath = ""
+	}
+
+	return false
+}
+
+// setRepositoryName sets the namespace and repository params from the full repository name routed
+func setRepositoryName() macaron.Handler {
+	return func(ctx *macaron.Context) {
+		name := ctx.Params(":name")
+		if name == "" {
+			return
+		}
+
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		namespace, repository := module.SplitRepositoryName(name)
+		ctx.SetParams(":name", name)
+		ctx.SetParams(":namespace", namespace)
+		ctx.SetParams(":repository", repository)
+	}
+}
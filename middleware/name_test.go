@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/wrench/setting"
+)
+
+func Test_repositoryName(t *testing.T) {
+	setting.DefaultNamespace = "library"
+
+	m := macaron.New()
+	m.Before(rewriteRepositoryName)
+	m.Use(setRepositoryName())
+	m.Group("/v2", func() {
+		m.Get("/:name/manifests/:tag", func(ctx *macaron.Context) string {
+			return fmt.Sprintf("%v|%v|%v|%v", ctx.Params(":name"), ctx.Params(":namespace"), ctx.Params(":repository"), ctx.Params(":tag"))
+		})
+		m.Get("/:name/blobs/uploads/:uuid", func(ctx *macaron.Context) string {
+			return fmt.Sprintf("%v|%v|%v|%v", ctx.Params(":name"), ctx.Params(":namespace"), ctx.Params(":repository"), ctx.Params(":uuid"))
+		})
+		m.Get("/:name/referrers/:digest", func(ctx *macaron.Context) string {
+			return fmt.Sprintf("%v|%v|%v|%v", ctx.Params(":name"), ctx.Params(":namespace"), ctx.Params(":repository"), ctx.Params(":digest"))
+		})
+	})
+
+	cases := map[string]string{
+		"/v2/busybox/manifests/latest":                  "busybox|library|busybox|latest",
+		"/v2/containerops/dockyard/manifests/v1":        "containerops/dockyard|containerops|dockyard|v1",
+		"/v2/team/project/service/manifests/latest":     "team/project/service|team/project|service|latest",
+		"/v2/team/project/service/blobs/uploads/abc123": "team/project/service|team/project|service|abc123",
+		"/v2/team/project/service/referrers/sha256:ab":  "team/project/service|team/project|service|sha256:ab",
+	}
+
+	for path, expected := range cases {
+		req, _ := http.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		m.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK || resp.Body.String() != expected {
+			t.Errorf("%v: expected %v, got %v %v", path, expected, resp.Code, resp.Body.String())
+		}
+	}
+}
@@ -3,6 +3,7 @@ package notifications
 import (
 	"bytes"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/satori/go.uuid"
@@ -71,13 +72,23 @@ func (b *bridge) createManifestEventAndWrite(action string, repo string, sm *Sig
 
 func (b *bridge) createManifestEvent(action string, repo string, sm *SignedManifest) (*Event, error) {
 	event := b.createEvent(action)
-	event.Target.MediaType = ManifestMediaType
 	event.Target.Repository = repo
 
-	p, err := utils.Payload(sm.Raw)
+	mediatype, err := module.GetManifestMediaType(sm.Raw, "")
 	if err != nil {
 		return nil, err
 	}
+	event.Target.MediaType = mediatype
+
+	//only schema1 manifests are signed, the others are digested as they are
+	p, err := utils.Payload(sm.Raw)
+	if err != nil {
+		if !strings.Contains(err.Error(), "missing signature key") {
+			return nil, err
+		}
+
+		p = sm.Raw
+	}
 
 	event.Target.Length = int64(len(p))
 	event.Target.Digest, err = utils.FromReader(bytes.NewReader(p))
@@ -92,6 +103,14 @@ func (b *bridge) createManifestEvent(action string, repo string, sm *SignedManif
 	return event, nil
 }
 
+func (b *bridge) createDeleteEventAndWrite(repo string, desc Descriptor) Error {
+	event := b.createEvent(EventActionDelete)
+	event.Target.Descriptor = desc
+	event.Target.Repository = repo
+
+	return b.Sink.Write(*event)
+}
+
 func (b *bridge) createEvent(action string) *Event {
 	event := &Event{
 		ID:        utils.MD5(uuid.NewV4().String()),
@@ -6,15 +6,15 @@ import (
 )
 
 const (
-	ManifestMediaType = "application/vnd.docker.distribution.manifest.v1+json"
-	BlobMediaType     = "application/vnd.docker.distribution.manifest.v1+json" // TBD: to be confirm with reciever
-	DefaultMedisType  = "application/octet-stream"
-	EventsMediaType   = "application/vnd.docker.distribution.events.v1+json"
+	BlobMediaType    = "application/vnd.docker.distribution.manifest.v1+json" // TBD: to be confirm with reciever
+	DefaultMedisType = "application/octet-stream"
+	EventsMediaType  = "application/vnd.docker.distribution.events.v1+json"
 )
 
 const (
-	EventActionPull = "pull"
-	EventActionPush = "push"
+	EventActionPull   = "pull"
+	EventActionPush   = "push"
+	EventActionDelete = "delete"
 )
 
 type Envelope struct {
@@ -9,7 +9,6 @@ import (
 
 	"gopkg.in/macaron.v1"
 
-	"github.com/containerops/dockyard/handler"
 	"github.com/containerops/dockyard/middleware"
 	"github.com/containerops/dockyard/models"
 	"github.com/containerops/dockyard/module"
@@ -59,6 +58,10 @@ func (n *notification) Handler(ctx *macaron.Context) {
 
 	switch ctx.Req.Method {
 	case "HEAD":
+		if flag := strings.Contains(ctx.Req.RequestURI, "/blobs/"); flag == false {
+			return
+		}
+
 		digest := ctx.Params(":digest")
 		tarsum := strings.Split(digest, ":")[1]
 
@@ -128,27 +131,27 @@ func (n *notification) Handler(ctx *macaron.Context) {
 		}
 
 		if flag := strings.Contains(ctx.Req.RequestURI, "/manifests/"); flag == true {
-			t := new(models.Tag)
-			if err := t.Get(namespace, repository, ctx.Params(":tag")); err != nil {
+			has, manifest, _, err := module.GetManifest(namespace, repository, ctx.Params(":tag"))
+			if err != nil || has == false {
 				return
 			}
 
-			digest, err := utils.DigestManifest([]byte(t.Manifest))
+			digest, err := utils.DigestManifest(manifest)
 			if err != nil {
 				fmt.Errorf("[REGISTRY API V2] Get manifest digest failed: %v", err.Error())
 				return
 			}
 
 			var sm SignedManifest
-			if err := json.Unmarshal([]byte(t.Manifest), &sm); err != nil {
+			if err := json.Unmarshal(manifest, &sm); err != nil {
 				fmt.Errorf("Unmarshal manifest error")
 			}
 
-			sm.Raw = make([]byte, len(t.Manifest), len(t.Manifest))
-			copy(sm.Raw, []byte(t.Manifest))
+			sm.Raw = make([]byte, len(manifest), len(manifest))
+			copy(sm.Raw, manifest)
 
 			req := newReqRecord(utils.EncodeBasicAuth(namespace, "getmanifestv2"), ctx.Req.Request)
-			requrl, err := module.NewURLBuilderFromRequest(ctx.Req.Request).BuildManifestURL(sm.Name, digest)
+			requrl, err := module.NewURLBuilderFromRequest(ctx.Req.Request).BuildManifestURL(repo, digest)
 			if err != nil {
 				fmt.Errorf("[REGISTRY API V2] Get manifest and get request URL failed, error:: %v", err.Error())
 			}
@@ -199,9 +202,7 @@ func (n *notification) Handler(ctx *macaron.Context) {
 		}
 
 		if flag := strings.Contains(ctx.Req.RequestURI, "/manifests/"); flag == true {
-			buf, _ := ctx.Req.Body().Bytes()
-
-			handler.ManifestCtx = buf
+			buf, _ := middleware.GetRequestBody(ctx).Bytes()
 
 			var sm SignedManifest
 			if err := json.Unmarshal(buf, &sm); err != nil {
@@ -217,7 +218,7 @@ func (n *notification) Handler(ctx *macaron.Context) {
 				//return http.StatusBadRequest, result
 			}
 			req := newReqRecord(utils.EncodeBasicAuth(namespace, "putmanifestv2"), ctx.Req.Request)
-			requrl, err := module.NewURLBuilderFromRequest(ctx.Req.Request).BuildManifestURL(sm.Name, digest)
+			requrl, err := module.NewURLBuilderFromRequest(ctx.Req.Request).BuildManifestURL(repo, digest)
 			if err != nil {
 				fmt.Errorf("[REGISTRY API V2] Put manifest and get request URL failed, error:: %v", err.Error())
 			}
@@ -234,6 +235,57 @@ func (n *notification) Handler(ctx *macaron.Context) {
 			return
 		}
 
+	case "DELETE":
+		if setting.DeleteEnabled == false {
+			return
+		}
+
+		var desc Descriptor
+		if flag := strings.Contains(ctx.Req.RequestURI, "/manifests/"); flag == true {
+			reference := ctx.Params(":tag")
+			if !module.IsDigest(reference) {
+				return
+			}
+
+			has, _, mediatype, err := module.GetManifest(namespace, repository, reference)
+			if err != nil || has == false {
+				return
+			}
+
+			desc = Descriptor{
+				MediaType: mediatype,
+				Digest:    reference,
+			}
+		} else if flag := strings.Contains(ctx.Req.RequestURI, "/blobs/uploads/"); flag == true {
+			return
+		} else if flag := strings.Contains(ctx.Req.RequestURI, "/blobs/"); flag == true {
+			digest := ctx.Params(":digest")
+
+			r := new(models.Repository)
+			if has, err := r.HasBlob(namespace, repository, digest); err != nil || has == false {
+				return
+			}
+
+			desc = Descriptor{
+				MediaType: DefaultMedisType,
+				Digest:    digest,
+			}
+		} else {
+			return
+		}
+
+		req := newReqRecord(utils.EncodeBasicAuth(namespace, "deletev2"), ctx.Req.Request)
+
+		b := newBridge("", actor, req, notice)
+		Err := b.createDeleteEventAndWrite(repo, desc)
+		if Err.Err != nil {
+			ctx.Resp.WriteHeader(http.StatusForbidden)
+			return
+		} else if Err.StatusCode >= 300 {
+			ctx.Resp.WriteHeader(Err.StatusCode)
+			return
+		}
+
 	default:
 		return
 	}
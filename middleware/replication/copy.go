@@ -0,0 +1,149 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+)
+
+// replicate copies the manifest of tag to remote with the blobs remote misses,
+// children of a manifest list or an image index are copied by digest before it.
+func (r *rule) replicate(t task) error {
+	has, data, mediatype, err := module.GetManifest(t.Namespace, t.Repository, t.Tag)
+	if err != nil {
+		return err
+	} else if has == false {
+		return fmt.Errorf("Tag not found")
+	}
+
+	name := fmt.Sprintf("%v/%v", t.Namespace, t.Repository)
+
+	if module.IsManifestList(mediatype) {
+		var list module.ManifestList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+
+		for _, desc := range list.Manifests {
+			m := new(models.Manifest)
+			if has, _, err := m.Has(t.Namespace, t.Repository, desc.Digest); err != nil {
+				return err
+			} else if has == false {
+				return fmt.Errorf("Manifest not found: %v", desc.Digest)
+			}
+
+			if err := r.pushManifest(name, desc.Digest, []byte(m.Manifest), m.MediaType); err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.pushManifest(name, t.Tag, data, mediatype)
+}
+
+func (r *rule) pushManifest(name, reference string, data []byte, mediatype string) error {
+	blobs, err := module.ManifestBlobs(data, mediatype)
+	if err != nil {
+		return err
+	}
+
+	for _, blob := range blobs {
+		//foreign layers are pulled from their urls and never pushed
+		if (blob.MediaType == module.ForeignLayerMediaType || blob.MediaType == module.OCIForeignLayerMediaType) && len(blob.URLs) > 0 {
+			continue
+		}
+
+		if err := r.pushBlob(name, blob.Digest); err != nil {
+			return err
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", mediatype)
+
+	resp, err := r.remote.Do(name, "PUT", fmt.Sprintf("/v2/%v/manifests/%v", name, reference), header, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
+		return responseError(fmt.Sprintf("Push manifest %v", reference), resp)
+	}
+
+	return nil
+}
+
+// pushBlob uploads the blob monolithically when remote doesn't have it
+func (r *rule) pushBlob(name, digest string) error {
+	resp, err := r.remote.Do(name, "HEAD", fmt.Sprintf("/v2/%v/blobs/%v", name, digest), http.Header{}, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("Invalid blob digest: %v", digest)
+	}
+
+	i := new(models.Image)
+	if has, _ := i.HasTarsum(parts[1]); has == false {
+		return fmt.Errorf("Blob not found: %v", digest)
+	}
+
+	fd, err := os.Open(i.Path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	if resp, err = r.remote.Do(name, "POST", fmt.Sprintf("/v2/%v/blobs/uploads/", name), http.Header{}, nil); err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return responseError(fmt.Sprintf("Start upload of blob %v", digest), resp)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return err
+	}
+
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+
+	if resp, err = r.remote.Do(name, "PUT", location.String(), header, fd); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return responseError(fmt.Sprintf("Upload blob %v", digest), resp)
+	}
+
+	return nil
+}
+
+func responseError(action string, resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("%v responds %v: %v", action, resp.Status, strings.TrimSpace(string(body)))
+}
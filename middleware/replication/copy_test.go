@@ -0,0 +1,237 @@
+package replication
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/wrench/db"
+	"github.com/containerops/wrench/setting"
+)
+
+// initDB connects the redis of DOCKYARD_TEST_REDIS or localhost:6379 with db 15,
+// tests reading manifests and blobs are skipped without redis.
+func initDB(t *testing.T) {
+	addr := os.Getenv("DOCKYARD_TEST_REDIS")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	if err := db.InitDB(addr, "", 15); err != nil {
+		t.Skipf("Redis isn't available: %v", err)
+	}
+}
+
+// peer is a remote registry which has the blobs in it, receives uploads and records the requests
+type peer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	blobs    map[string][]byte
+	requests []string
+}
+
+func newPeer(blobs ...string) *peer {
+	p := &peer{blobs: map[string][]byte{}}
+	for _, blob := range blobs {
+		p.blobs[blob] = nil
+	}
+
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.requests = append(p.requests, r.Method+" "+r.URL.Path)
+
+		switch {
+		case r.Method == "HEAD" && strings.Contains(r.URL.Path, "/blobs/"):
+			if _, has := p.blobs[r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]]; has {
+				w.WriteHeader(http.StatusOK)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/blobs/uploads/"):
+			w.Header().Set("Location", r.URL.Path+"upload-1?_state=abc")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == "PUT" && strings.Contains(r.URL.Path, "/blobs/uploads/"):
+			data, _ := ioutil.ReadAll(r.Body)
+			p.blobs[r.URL.Query().Get("digest")] = data
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "PUT" && strings.Contains(r.URL.Path, "/manifests/"):
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return p
+}
+
+func (p *peer) received() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string{}, p.requests...)
+}
+
+func digestOf(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+func Test_pushBlobSkipsExisting(t *testing.T) {
+	digest := digestOf([]byte("layer"))
+
+	p := newPeer(digest)
+	defer p.Close()
+
+	r := newRule(setting.ReplicationRule{Name: "dc2", URL: p.URL})
+	if err := r.pushBlob("library/busybox", digest); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"HEAD /v2/library/busybox/blobs/" + digest}
+	if requests := p.received(); strings.Join(requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected only %v sent for blob remote has, got %v", expected, requests)
+	}
+}
+
+func Test_replicateManifestList(t *testing.T) {
+	initDB(t)
+
+	dir, err := ioutil.TempDir("", "replication")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setting.ImagePath = dir
+
+	layer := []byte("layer of amd64")
+	digest, path, size, err := module.StoreBlob(strings.NewReader(string(layer)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &models.Image{Path: path, Size: size}
+	if err := i.PutTarsum(strings.TrimPrefix(digest, "sha256:")); err != nil {
+		t.Fatal(err)
+	}
+
+	config := digestOf([]byte("config"))
+	namespace, repository := "replication", fmt.Sprintf("list%d", time.Now().UnixNano())
+
+	children := []string{}
+	list := module.ManifestList{SchemaVersion: 2, MediaType: module.ManifestListMediaType}
+	for _, architecture := range []string{"amd64", "arm64"} {
+		manifest := module.ManifestV2{
+			SchemaVersion: 2,
+			MediaType:     module.ManifestV2MediaType,
+			Config:        module.Descriptor{MediaType: module.ImageConfigMediaType, Digest: config},
+			Layers:        []module.Descriptor{{MediaType: module.LayerMediaType, Digest: digest, Size: size}},
+		}
+		data, _ := json.Marshal(manifest)
+
+		m := new(models.Manifest)
+		if err := m.Put(namespace, repository, digestOf(data), "", string(data), module.ManifestV2MediaType); err != nil {
+			t.Fatal(err)
+		}
+
+		children = append(children, digestOf(data))
+		list.Manifests = append(list.Manifests, module.Descriptor{
+			MediaType: module.ManifestV2MediaType,
+			Digest:    digestOf(data),
+			Size:      int64(len(data)),
+			Platform:  &module.Platform{Architecture: architecture, OS: "linux"},
+		})
+	}
+
+	data, _ := json.Marshal(list)
+	tag := &models.Tag{Name: "latest", Namespace: namespace, Repository: repository, Manifest: string(data), MediaType: module.ManifestListMediaType}
+	if err := tag.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newPeer(config)
+	defer p.Close()
+
+	r := newRule(setting.ReplicationRule{Name: "dc2", URL: p.URL})
+	if err := r.replicate(task{Namespace: namespace, Repository: repository, Tag: "latest"}); err != nil {
+		t.Fatal(err)
+	}
+
+	name := namespace + "/" + repository
+	manifests := []string{}
+	for _, request := range p.received() {
+		if strings.HasPrefix(request, "PUT /v2/"+name+"/manifests/") {
+			manifests = append(manifests, strings.TrimPrefix(request, "PUT /v2/"+name+"/manifests/"))
+		}
+	}
+
+	expected := append(append([]string{}, children...), "latest")
+	if strings.Join(manifests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected manifests pushed in order %v, got %v", expected, manifests)
+	}
+
+	if string(p.blobs[digest]) != string(layer) {
+		t.Errorf("Expected layer remote misses uploaded, got %q", p.blobs[digest])
+	}
+
+	for _, request := range p.received() {
+		if strings.HasPrefix(request, "POST ") && strings.Contains(request, config) {
+			t.Errorf("Expected config remote has not uploaded, got %v", request)
+		}
+	}
+}
+
+func Test_ruleRunRetries(t *testing.T) {
+	initDB(t)
+
+	middleware.Log = logs.NewLogger(10000)
+
+	p := newPeer()
+	defer p.Close()
+
+	r := newRule(setting.ReplicationRule{Name: "dc2", URL: p.URL, Threshold: 2, BackoffMs: 1})
+
+	missing := task{Namespace: "replication", Repository: fmt.Sprintf("missing%d", time.Now().UnixNano()), Tag: "latest"}
+	r.enqueue(missing)
+	r.run(<-r.queue)
+
+	status := r.status()
+	if status.Pending != 1 || status.Failed != 0 || status.LastError == nil || status.LastError.Attempts != 1 {
+		t.Fatalf("Expected tag pending for retry after first failure, got %+v", status)
+	}
+
+	select {
+	case retried := <-r.queue:
+		if retried.attempts != 1 {
+			t.Errorf("Expected retry with 1 attempt recorded, got %v", retried.attempts)
+		}
+		r.run(retried)
+	case <-time.After(time.Second):
+		t.Fatal("Expected tag queued again after backoff")
+	}
+
+	status = r.status()
+	if status.Pending != 0 || status.Failed != 1 || status.LastError.Attempts != 2 {
+		t.Errorf("Expected tag given up after threshold attempts, got %+v", status)
+	}
+
+	select {
+	case retried := <-r.queue:
+		t.Errorf("Expected no retry after threshold, got %v", retried)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
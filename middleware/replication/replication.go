@@ -0,0 +1,85 @@
+package replication
+
+import (
+	"strings"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/wrench/setting"
+)
+
+type replication struct{}
+
+var rules []*rule
+
+func init() {
+	middleware.Register("replication", middleware.HandlerInterface(&replication{}))
+}
+
+func (r *replication) InitFunc() error {
+	rules = []*rule{}
+
+	for _, desc := range setting.JSONConfCtx.Replication.Rules {
+		if desc.Disabled {
+			continue
+		}
+
+		rule := newRule(desc)
+		rule.start()
+		rules = append(rules, rule)
+	}
+
+	return nil
+}
+
+func (r *replication) Handler(ctx *macaron.Context) {
+}
+
+// After queues the tag pushed to every rule matches it once the manifest is saved,
+// manifests pushed by digest are copied with the tag which references them.
+func (r *replication) After(ctx *macaron.Context) {
+	if ctx.Req.Method != "PUT" || !strings.Contains(ctx.Req.URL.Path, "/manifests/") {
+		return
+	}
+
+	namespace := ctx.Params(":namespace")
+	repository := ctx.Params(":repository")
+	tag := ctx.Params(":tag")
+
+	if status := ctx.Resp.Status(); status != 201 && status != 202 {
+		return
+	}
+
+	if module.IsDigest(tag) {
+		return
+	}
+
+	for _, rule := range rules {
+		if rule.match(namespace, repository, tag) {
+			rule.enqueue(task{Namespace: namespace, Repository: repository, Tag: tag})
+		}
+	}
+}
+
+// Status returns the status of all enabled rules
+func Status() []RuleStatus {
+	status := []RuleStatus{}
+	for _, rule := range rules {
+		status = append(status, rule.status())
+	}
+
+	return status
+}
+
+// GetStatus returns the status of rule by its name
+func GetStatus(name string) (RuleStatus, bool) {
+	for _, rule := range rules {
+		if rule.Name == name {
+			return rule.status(), true
+		}
+	}
+
+	return RuleStatus{}, false
+}
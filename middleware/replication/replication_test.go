@@ -0,0 +1,62 @@
+package replication
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/wrench/setting"
+)
+
+// Test_replicationAfter pushes manifests through the middlewares, tags are queued only after the handler saved them
+func Test_replicationAfter(t *testing.T) {
+	setting.JSONConfCtx.Replication = setting.ReplicationCtx{Name: "replication"}
+	defer func() { setting.JSONConfCtx.Replication = setting.ReplicationCtx{} }()
+
+	r := newRule(setting.ReplicationRule{Name: "dc2", URL: "http://localhost:1", Tags: []string{"v*"}})
+	rules = []*rule{r}
+	defer func() { rules = nil }()
+
+	handled := []string{}
+	m := macaron.New()
+	m.Use(middleware.Handlefunc())
+	m.Put("/v2/:namespace/:repository/manifests/:tag", func(ctx *macaron.Context) (int, string) {
+		handled = append(handled, ctx.Params(":tag"))
+
+		//the tag isn't queued before the handler saves it
+		if ctx.Params(":tag") == "v1" && len(r.queue) != 0 {
+			t.Errorf("Expected v1 queued after the handler, got %v queued", len(r.queue))
+		}
+
+		if ctx.Params(":repository") == "invalid" {
+			return http.StatusBadRequest, "manifest invalid"
+		}
+		return http.StatusCreated, ""
+	})
+
+	for _, path := range []string{
+		"/v2/library/busybox/manifests/v1",
+		"/v2/library/busybox/manifests/latest",
+		"/v2/library/invalid/manifests/v2",
+		"/v2/library/busybox/manifests/sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4",
+	} {
+		req, _ := http.NewRequest("PUT", path, strings.NewReader("{}"))
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(handled) != 4 {
+		t.Errorf("Expected every manifest handled once, got %v", handled)
+	}
+
+	if len(r.queue) != 1 {
+		t.Fatalf("Expected only the tag saved and matched queued, got %v", len(r.queue))
+	}
+
+	if queued := <-r.queue; queued.Namespace != "library" || queued.Repository != "busybox" || queued.Tag != "v1" {
+		t.Errorf("Expected library/busybox:v1 queued, got %v", queued)
+	}
+}
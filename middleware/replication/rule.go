@@ -0,0 +1,179 @@
+package replication
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/wrench/setting"
+)
+
+const (
+	queueSize  = 1000
+	maxBackoff = time.Hour
+)
+
+type task struct {
+	Namespace  string
+	Repository string
+	Tag        string
+	attempts   int
+}
+
+func (t task) String() string {
+	return fmt.Sprintf("%v/%v:%v", t.Namespace, t.Repository, t.Tag)
+}
+
+type Result struct {
+	Tag      string    `json:"tag"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type RuleStatus struct {
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Repositories  []string `json:"repositories"`
+	Tags          []string `json:"tags"`
+	Pending       int      `json:"pending"`   // tags queued or waiting to retry
+	Succeeded     int64    `json:"succeeded"` //
+	Failed        int64    `json:"failed"`    // tags given up after threshold attempts
+	LastSucceeded *Result  `json:"lastsucceeded,omitempty"`
+	LastError     *Result  `json:"lasterror,omitempty"`
+}
+
+// rule copies the tags it matches to a remote registry one by one,
+// a failed tag is retried with exponential backoff until threshold attempts.
+type rule struct {
+	setting.ReplicationRule
+
+	remote  *module.Remote
+	queue   chan task
+	backoff time.Duration
+
+	mu            sync.Mutex
+	pending       int
+	succeeded     int64
+	failed        int64
+	lastSucceeded *Result
+	lastError     *Result
+}
+
+func newRule(desc setting.ReplicationRule) *rule {
+	r := &rule{
+		ReplicationRule: desc,
+		remote:          module.NewRemote(desc.URL, desc.Username, desc.Password),
+		queue:           make(chan task, queueSize),
+	}
+
+	if r.Threshold <= 0 {
+		r.Threshold = 5
+	}
+
+	r.backoff = 5 * time.Second
+	if r.BackoffMs > 0 {
+		r.backoff = time.Duration(r.BackoffMs) * time.Millisecond
+	}
+
+	return r
+}
+
+// match reports whether the repository and tag are selected by the globs of rule, an empty list selects all
+func (r *rule) match(namespace, repository, tag string) bool {
+	return matchGlobs(r.Repositories, fmt.Sprintf("%v/%v", namespace, repository)) && matchGlobs(r.Tags, tag)
+}
+
+func matchGlobs(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *rule) enqueue(t task) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case r.queue <- t:
+		r.pending++
+	default:
+		r.failed++
+		r.lastError = &Result{Tag: t.String(), Error: "Replication queue is full", Time: time.Now()}
+		middleware.Log.Error("[REPLICATION] Queue of %v is full, %v dropped", r.Name, t)
+	}
+}
+
+func (r *rule) start() {
+	go func() {
+		for t := range r.queue {
+			r.run(t)
+		}
+	}()
+}
+
+func (r *rule) run(t task) {
+	t.attempts++
+	err := r.replicate(t)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &Result{Tag: t.String(), Attempts: t.attempts, Time: time.Now()}
+
+	if err == nil {
+		r.pending--
+		r.succeeded++
+		r.lastSucceeded = result
+		middleware.Log.Info("[REPLICATION] Replicated %v to %v", t, r.Name)
+		return
+	}
+
+	result.Error = err.Error()
+	r.lastError = result
+
+	if t.attempts >= r.Threshold {
+		r.pending--
+		r.failed++
+		middleware.Log.Error("[REPLICATION] Replicate %v to %v failed after %v attempts: %v", t, r.Name, t.attempts, err.Error())
+		return
+	}
+
+	backoff := r.backoff << uint(t.attempts-1)
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+
+	middleware.Log.Warn("[REPLICATION] Replicate %v to %v failed: %v, retry in %v", t, r.Name, err.Error(), backoff)
+	time.AfterFunc(backoff, func() {
+		r.queue <- t
+	})
+}
+
+func (r *rule) status() RuleStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return RuleStatus{
+		Name:          r.Name,
+		URL:           r.URL,
+		Repositories:  r.Repositories,
+		Tags:          r.Tags,
+		Pending:       r.pending,
+		Succeeded:     r.succeeded,
+		Failed:        r.failed,
+		LastSucceeded: r.lastSucceeded,
+		LastError:     r.lastError,
+	}
+}
@@ -0,0 +1,38 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/containerops/wrench/setting"
+)
+
+func Test_ruleMatch(t *testing.T) {
+	r := newRule(setting.ReplicationRule{
+		Name:         "dc2",
+		Repositories: []string{"library/*", "team/project/*"},
+		Tags:         []string{"v*", "latest"},
+	})
+
+	cases := []struct {
+		namespace, repository, tag string
+		expected                   bool
+	}{
+		{"library", "busybox", "latest", true},
+		{"library", "busybox", "v1.2", true},
+		{"library", "busybox", "dev", false},
+		{"team/project", "service", "v2", true},
+		{"team", "project", "latest", false},
+		{"containerops", "dockyard", "latest", false},
+	}
+
+	for _, c := range cases {
+		if matched := r.match(c.namespace, c.repository, c.tag); matched != c.expected {
+			t.Errorf("%v/%v:%v: expected %v, got %v", c.namespace, c.repository, c.tag, c.expected, matched)
+		}
+	}
+
+	all := newRule(setting.ReplicationRule{Name: "all"})
+	if !all.match("containerops", "dockyard", "dev") {
+		t.Errorf("rule without globs should match all tags")
+	}
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/dockyard/module/errcode"
+	"github.com/containerops/wrench/setting"
+)
+
+// checkToken requires a bearer token issued by the token service on every V2 request except the token service itself,
+// a request without token or with a token lacking the access it needs is challenged with the scope to request.
+// Nothing is checked when Dockyard runs standalone.
+func checkToken() macaron.Handler {
+	return func(ctx *macaron.Context) {
+		if setting.Standalone == "true" {
+			return
+		}
+
+		path := strings.TrimSuffix(ctx.Req.URL.Path, "/")
+		if (path != "/v2" && !strings.HasPrefix(path, "/v2/")) || path == "/v2/token" {
+			return
+		}
+
+		required := requiredAccess(ctx)
+
+		auth := ctx.Req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			challenge(ctx, required, "", "authentication required")
+			return
+		}
+
+		claims, err := module.VerifyToken(strings.TrimPrefix(auth, "Bearer "), setting.TokenService)
+		if err != nil {
+			challenge(ctx, required, "invalid_token", err.Error())
+			return
+		}
+
+		for _, access := range required {
+			for _, action := range access.Actions {
+				if !claims.Allows(access.Type, access.Name, action) {
+					challenge(ctx, required, "insufficient_scope", fmt.Sprintf("%v not granted", access))
+					return
+				}
+			}
+		}
+
+		//blobs are only mounted from repositories the token could pull, otherwise it falls back to an upload
+		if from := ctx.Query("from"); ctx.Req.Method == "POST" && ctx.Query("mount") != "" && !claims.Allows("repository", from, "pull") {
+			query := ctx.Req.URL.Query()
+			query.Del("mount")
+			query.Del("from")
+			ctx.Req.URL.RawQuery = query.Encode()
+		}
+
+		GetAccount(ctx).Name = claims.Subject
+	}
+}
+
+// requiredAccess returns the access V2 request needs, pinging the API only needs a valid token
+func requiredAccess(ctx *macaron.Context) []module.Access {
+	if name := ctx.Params(":name"); name != "" {
+		switch ctx.Req.Method {
+		case "GET", "HEAD":
+			return []module.Access{{Type: "repository", Name: name, Actions: []string{"pull"}}}
+		case "DELETE":
+			return []module.Access{{Type: "repository", Name: name, Actions: []string{"delete"}}}
+		default:
+			return []module.Access{{Type: "repository", Name: name, Actions: []string{"pull", "push"}}}
+		}
+	}
+
+	if strings.HasPrefix(ctx.Req.URL.Path, "/v2/_catalog") {
+		return []module.Access{{Type: "registry", Name: "catalog", Actions: []string{"*"}}}
+	}
+
+	return []module.Access{}
+}
+
+// challenge responds 401 with the bearer challenge telling client where to request a token with which scope
+func challenge(ctx *macaron.Context, required []module.Access, reason, detail string) {
+	params := []string{
+		fmt.Sprintf("realm=%q", setting.TokenRealm),
+		fmt.Sprintf("service=%q", setting.TokenService),
+	}
+
+	if len(required) > 0 {
+		scopes := []string{}
+		for _, access := range required {
+			scopes = append(scopes, access.String())
+		}
+
+		params = append(params, fmt.Sprintf("scope=%q", strings.Join(scopes, " ")))
+	}
+
+	if reason != "" {
+		params = append(params, fmt.Sprintf("error=%q", reason))
+	}
+
+	Log.Info("[REGISTRY API V2] Unauthorized %v %v: %v", ctx.Req.Method, ctx.Req.URL.Path, detail)
+
+	ctx.Resp.Header().Set("WWW-Authenticate", "Bearer "+strings.Join(params, ","))
+	errcode.Unauthorized.Write(ctx.Resp, detail)
+}
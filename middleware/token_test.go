@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"gopkg.in/macaron.v1"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/dockyard/module"
+	"github.com/containerops/wrench/db"
+	"github.com/containerops/wrench/setting"
+)
+
+// initDB connects the redis of DOCKYARD_TEST_REDIS or localhost:6379 with db 15,
+// tests granting access by permissions are skipped without redis.
+func initDB(t *testing.T) {
+	addr := os.Getenv("DOCKYARD_TEST_REDIS")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	if err := db.InitDB(addr, "", 15); err != nil {
+		t.Skipf("Redis isn't available: %v", err)
+	}
+}
+
+func grantAccess(t *testing.T, user string, requested []module.Access) []module.Access {
+	granted, err := module.GrantAccess(user, requested)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return granted
+}
+
+func Test_checkToken(t *testing.T) {
+	initDB(t)
+
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := module.LoadSigningKey(filepath.Join(dir, "signing.key")); err != nil {
+		t.Fatal(err)
+	}
+
+	Log = logs.NewLogger(10000)
+	setting.Standalone, setting.DefaultNamespace = "false", "library"
+	setting.TokenRealm, setting.TokenService, setting.TokenIssuer = "https://containerops.me/v2/token", "containerops.me", "dockyard"
+	setting.TokenExpiration = time.Minute
+
+	//team is owned by nobody, so only administrators push to it
+	setting.Administrators = []string{"somebody"}
+	defer func() { setting.Administrators = nil }()
+
+	r := &models.Repository{Namespace: "team", Repository: "app"}
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := macaron.New()
+	m.Before(rewriteRepositoryName)
+	m.Use(setRepositoryName())
+	m.Use(setAccount())
+	m.Use(checkToken())
+	m.Group("/v2", func() {
+		m.Get("/", func() string { return "pong" })
+		m.Get("/:name/manifests/:tag", func(account *Account) string { return account.Name })
+		m.Put("/:name/manifests/:tag", func(account *Account) string { return account.Name })
+	})
+
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp := httptest.NewRecorder()
+		m.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve("GET", "/v2/team/app/manifests/latest", "")
+	expected := `Bearer realm="https://containerops.me/v2/token",service="containerops.me",scope="repository:team/app:pull"`
+	if resp.Code != http.StatusUnauthorized || resp.Header().Get("WWW-Authenticate") != expected {
+		t.Errorf("Expected challenge %v, got %v %v", expected, resp.Code, resp.Header().Get("WWW-Authenticate"))
+	}
+
+	pull, _, err := module.IssueToken("", setting.TokenService, grantAccess(t, "", []module.Access{{Type: "repository", Name: "team/app", Actions: []string{"pull", "push"}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := serve("GET", "/v2/team/app/manifests/latest", pull); resp.Code != http.StatusOK {
+		t.Errorf("Expected anonymous pull allowed, got %v", resp.Code)
+	}
+
+	resp = serve("PUT", "/v2/team/app/manifests/latest", pull)
+	if resp.Code != http.StatusUnauthorized || !strings.Contains(resp.Header().Get("WWW-Authenticate"), `scope="repository:team/app:pull,push",error="insufficient_scope"`) {
+		t.Errorf("Expected anonymous push challenged, got %v %v", resp.Code, resp.Header().Get("WWW-Authenticate"))
+	}
+
+	push, _, err := module.IssueToken("somebody", setting.TokenService, grantAccess(t, "somebody", []module.Access{{Type: "repository", Name: "team/app", Actions: []string{"pull", "push"}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := serve("PUT", "/v2/team/app/manifests/latest", push); resp.Code != http.StatusOK || resp.Body.String() != "somebody" {
+		t.Errorf("Expected push by somebody allowed, got %v %v", resp.Code, resp.Body.String())
+	}
+
+	if resp := serve("GET", "/v2/other/app/manifests/latest", push); resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected token of team/app refused by other/app, got %v", resp.Code)
+	}
+
+	if resp := serve("GET", "/v2/", push[:len(push)-4]+"AAAA"); resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected forged token refused, got %v", resp.Code)
+	}
+
+	if resp := serve("GET", "/v2/", push); resp.Code != http.StatusOK {
+		t.Errorf("Expected ping with token allowed, got %v", resp.Code)
+	}
+
+	setting.TokenService = "other.me"
+	if resp := serve("GET", "/v2/", push); resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected token of other service refused, got %v", resp.Code)
+	}
+}
+
+func Test_checkTokenPermissions(t *testing.T) {
+	initDB(t)
+
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := module.LoadSigningKey(filepath.Join(dir, "signing.key")); err != nil {
+		t.Fatal(err)
+	}
+
+	Log = logs.NewLogger(10000)
+	setting.Standalone, setting.DefaultNamespace = "false", "library"
+	setting.TokenRealm, setting.TokenService, setting.TokenIssuer = "https://containerops.me/v2/token", "containerops.me", "dockyard"
+	setting.TokenExpiration = time.Minute
+
+	owner := fmt.Sprintf("owner%d", time.Now().UnixNano())
+	u := new(models.User)
+	if err := u.Put(owner, owner+"@containerops.me", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	for repository, private := range map[string]bool{"public": false, "secret": true} {
+		r := &models.Repository{Namespace: owner, Repository: repository, Privated: private}
+		if err := r.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nobody := fmt.Sprintf("nobody%d", time.Now().UnixNano())
+	setting.Administrators = []string{"admin"}
+	defer func() { setting.Administrators = nil }()
+
+	m := macaron.New()
+	m.Before(rewriteRepositoryName)
+	m.Use(setRepositoryName())
+	m.Use(setAccount())
+	m.Use(checkToken())
+	m.Group("/v2", func() {
+		m.Get("/:name/manifests/:tag", func(account *Account) string { return account.Name })
+		m.Put("/:name/manifests/:tag", func(account *Account) string { return account.Name })
+	})
+
+	serve := func(method, path, user, name string) int {
+		token, _, err := module.IssueToken(user, setting.TokenService, grantAccess(t, user, []module.Access{{Type: "repository", Name: name, Actions: []string{"pull", "push"}}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp := httptest.NewRecorder()
+		m.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	cases := []struct {
+		method, user, name string
+		expected           int
+	}{
+		{"GET", "", owner + "/public", http.StatusOK},
+		{"GET", "", owner + "/app", http.StatusUnauthorized},
+		{"PUT", "somebody", owner + "/app", http.StatusUnauthorized},
+		{"PUT", owner, owner + "/app", http.StatusOK},
+		{"GET", "", owner + "/secret", http.StatusUnauthorized},
+		{"GET", "somebody", owner + "/secret", http.StatusUnauthorized},
+		{"GET", owner, owner + "/secret", http.StatusOK},
+		{"PUT", "somebody", nobody + "/app", http.StatusUnauthorized},
+		{"PUT", "admin", nobody + "/app", http.StatusOK},
+		{"PUT", nobody, nobody + "/app", http.StatusOK},
+	}
+
+	for _, c := range cases {
+		if code := serve(c.method, "/v2/"+c.name+"/manifests/latest", c.user, c.name); code != c.expected {
+			t.Errorf("%v %v by %q: expected %v, got %v", c.method, c.name, c.user, c.expected, code)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"gopkg.in/redis.v3"
@@ -204,3 +205,31 @@ func (i *Image) PutTarsum(tarsum string) error {
 
 	return nil
 }
+
+func (i *Image) DeleteTarsum(tarsum string) error {
+	if _, err := db.Client.Del(db.Key("tarsum", tarsum)).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// List returns the ids of all images
+func (i *Image) List() ([]string, error) {
+	return db.Client.HKeys(db.GLOBAL_IMAGE_INDEX).Result()
+}
+
+// ListTarsums returns the digest hex of all blobs
+func (i *Image) ListTarsums() ([]string, error) {
+	keys, err := db.Client.Keys(db.Key("tarsum", "*")).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tarsums := []string{}
+	for _, key := range keys {
+		tarsums = append(tarsums, strings.TrimPrefix(key, db.Key("tarsum", "")))
+	}
+
+	return tarsums, nil
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/redis.v3"
+
+	"github.com/containerops/wrench/db"
+)
+
+type Manifest struct {
+	Digest     string `json:"digest"`     //
+	Namespace  string `json:"namespace"`  //
+	Repository string `json:"repository"` //
+	MediaType  string `json:"mediatype"`  //
+	ImageId    string `json:"imageid"`    // empty for manifest list and image index
+	Manifest   string `json:"manifest"`   //
+	Created    int64  `json:"created"`    //
+	Updated    int64  `json:"updated"`    //
+
+	Subject      string            `json:"subject"`      // digest of manifest which it refers to
+	ArtifactType string            `json:"artifacttype"` //
+	Annotations  map[string]string `json:"annotations"`  //
+}
+
+func (m *Manifest) Has(namespace, repository, digest string) (bool, string, error) {
+	if key := db.Key("manifest", namespace, repository, digest); len(key) <= 0 {
+		return false, "", fmt.Errorf("Invalid manifest key")
+	} else {
+		if err := db.Get(m, key); err != nil {
+			if err == redis.Nil {
+				return false, "", nil
+			} else {
+				return false, "", err
+			}
+		}
+
+		return true, key, nil
+	}
+}
+
+func (m *Manifest) Save() error {
+	key := db.Key("manifest", m.Namespace, m.Repository, m.Digest)
+
+	if err := db.Save(m, key); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HSet(db.GLOBAL_MANIFEST_INDEX, fmt.Sprintf("%s/%s@%s", m.Namespace, m.Repository, m.Digest), key).Result(); err != nil {
+		return err
+	}
+
+	if m.Subject != "" {
+		if _, err := db.Client.SAdd(db.Key("referrers", m.Namespace, m.Repository, m.Subject), m.Digest).Result(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Manifest) Put(namespace, repository, digest, imageId, manifest, mediatype string) error {
+	if has, _, err := m.Has(namespace, repository, digest); err != nil {
+		return err
+	} else if has == false {
+		m.Created = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+
+	m.Namespace, m.Repository, m.Digest, m.ImageId, m.Manifest, m.MediaType =
+		namespace, repository, digest, imageId, manifest, mediatype
+	m.Updated = time.Now().UnixNano() / int64(time.Millisecond)
+
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Manifest) Delete() error {
+	if _, err := db.Client.Del(db.Key("manifest", m.Namespace, m.Repository, m.Digest)).Result(); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HDel(db.GLOBAL_MANIFEST_INDEX, fmt.Sprintf("%s/%s@%s", m.Namespace, m.Repository, m.Digest)).Result(); err != nil {
+		return err
+	}
+
+	if m.Subject != "" {
+		if _, err := db.Client.SRem(db.Key("referrers", m.Namespace, m.Repository, m.Subject), m.Digest).Result(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PutSubject records the manifest as a referrer of subject digest in the same repository
+func (m *Manifest) PutSubject(subject, artifactType string, annotations map[string]string) error {
+	m.Subject, m.ArtifactType, m.Annotations = subject, artifactType, annotations
+
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Referrers returns the manifests in repository which refer to subject digest
+func (m *Manifest) Referrers(namespace, repository, subject string) ([]Manifest, error) {
+	digests, err := db.Client.SMembers(db.Key("referrers", namespace, repository, subject)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := []Manifest{}
+	for _, digest := range digests {
+		manifest := Manifest{}
+		if has, _, err := manifest.Has(namespace, repository, digest); err != nil {
+			return nil, err
+		} else if has == false {
+			continue
+		}
+
+		manifests = append(manifests, manifest)
+	}
+
+	return manifests, nil
+}
+
+// List returns all manifests of every repository
+func (m *Manifest) List() ([]Manifest, error) {
+	keys, err := db.Client.HVals(db.GLOBAL_MANIFEST_INDEX).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := []Manifest{}
+	for _, key := range keys {
+		manifest := Manifest{}
+		if err := db.Get(&manifest, key); err != nil {
+			if err == redis.Nil {
+				continue
+			}
+			return nil, err
+		}
+
+		manifests = append(manifests, manifest)
+	}
+
+	return manifests, nil
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/redis.v3"
+
+	"github.com/containerops/wrench/db"
+)
+
+// Permission levels granted on a repository, each one includes the lower ones
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+	PermissionAdmin = "admin"
+)
+
+// Permission is the access granted on a repository to users and teams,
+// it's kept apart from the repository so grants could be made before the first push.
+type Permission struct {
+	Namespace  string            `json:"namespace"`  //
+	Repository string            `json:"repository"` //
+	Users      map[string]string `json:"users"`      // username -> level
+	Teams      map[string]string `json:"teams"`      // organization/team -> level
+	Updated    int64             `json:"updated"`    //
+}
+
+func (p *Permission) Has(namespace, repository string) (bool, string, error) {
+	if key := db.Key("permission", namespace, repository); len(key) <= 0 {
+		return false, "", fmt.Errorf("Invalid permission key")
+	} else {
+		if err := db.Get(p, key); err != nil {
+			if err == redis.Nil {
+				p.Namespace, p.Repository = namespace, repository
+				p.Users, p.Teams = map[string]string{}, map[string]string{}
+				return false, "", nil
+			} else {
+				return false, "", err
+			}
+		}
+
+		if p.Users == nil {
+			p.Users = map[string]string{}
+		}
+
+		if p.Teams == nil {
+			p.Teams = map[string]string{}
+		}
+
+		return true, key, nil
+	}
+}
+
+func (p *Permission) Save() error {
+	p.Updated = time.Now().UnixNano() / int64(time.Millisecond)
+
+	return db.Save(p, db.Key("permission", p.Namespace, p.Repository))
+}
+
+// PutUser grants level to user, an empty level revokes the grant
+func (p *Permission) PutUser(namespace, repository, username, level string) error {
+	if _, _, err := p.Has(namespace, repository); err != nil {
+		return err
+	}
+
+	if level == "" {
+		delete(p.Users, username)
+	} else {
+		p.Users[username] = level
+	}
+
+	return p.Save()
+}
+
+// PutTeam grants level to team of organization, an empty level revokes the grant
+func (p *Permission) PutTeam(namespace, repository, organization, team, level string) error {
+	if _, _, err := p.Has(namespace, repository); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s/%s", organization, team)
+	if level == "" {
+		delete(p.Teams, name)
+	} else {
+		p.Teams[name] = level
+	}
+
+	return p.Save()
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"gopkg.in/redis.v3"
@@ -12,6 +13,7 @@ import (
 )
 
 type Repository struct {
+	Name          string   `json:"name"`          // full name of namespace and repository
 	Repository    string   `json:"repository"`    //
 	Namespace     string   `json:"namespace"`     //
 	NamespaceType bool     `json:"namespacetype"` //
@@ -49,6 +51,8 @@ type Tag struct {
 	Repository string   `json:"repository"` //
 	Sign       string   `json:"sign"`       //
 	Manifest   string   `json:"manifest"`   //
+	MediaType  string   `json:"mediatype"`  // media type of manifest
+	Fetched    int64    `json:"fetched"`    // time fetched from upstream in proxy mode, zero for pushed tags
 	Memo       []string `json:"memo"`       //
 }
 
@@ -70,12 +74,13 @@ func (r *Repository) Has(namespace, repository string) (bool, string, error) {
 
 func (r *Repository) Save() error {
 	key := db.Key("repository", r.Namespace, r.Repository)
+	r.Name = fmt.Sprintf("%s/%s", r.Namespace, r.Repository)
 
 	if err := db.Save(r, key); err != nil {
 		return err
 	}
 
-	if _, err := db.Client.HSet(db.GLOBAL_REPOSITORY_INDEX, (fmt.Sprintf("%s/%s", r.Namespace, r.Repository)), key).Result(); err != nil {
+	if _, err := db.Client.HSet(db.GLOBAL_REPOSITORY_INDEX, r.Name, key).Result(); err != nil {
 		return err
 	}
 
@@ -96,6 +101,31 @@ func (t *Tag) Save() error {
 	return nil
 }
 
+// Delete removes the tag and every entry of it in the tag index, which is keyed by the image ids the tag has been pushed with
+func (t *Tag) Delete() error {
+	if _, err := db.Client.Del(db.Key("tag", t.Namespace, t.Repository, t.Name)).Result(); err != nil {
+		return err
+	}
+
+	fields, err := db.Client.HKeys(db.GLOBAL_TAG_INDEX).Result()
+	if err != nil {
+		return err
+	}
+
+	prefix := fmt.Sprintf("%s/%s/%s:", t.Namespace, t.Repository, t.Name)
+	for _, field := range fields {
+		if !strings.HasPrefix(field, prefix) {
+			continue
+		}
+
+		if _, err := db.Client.HDel(db.GLOBAL_TAG_INDEX, field).Result(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (t *Tag) Get(namespace, repository, tag string) error {
 	key := db.Key("tag", namespace, repository, tag)
 
@@ -241,7 +271,7 @@ func (r *Repository) PutJSONFromManifests(image map[string]string, namespace, re
 	return nil
 }
 
-func (r *Repository) PutTagFromManifests(image, namespace, repository, tag, manifests string) error {
+func (r *Repository) PutTagFromManifests(image, namespace, repository, tag, manifests, mediatype string) error {
 	if has, _, err := r.Has(namespace, repository); err != nil {
 		return err
 	} else if has == false {
@@ -249,7 +279,7 @@ func (r *Repository) PutTagFromManifests(image, namespace, repository, tag, mani
 	}
 
 	t := new(Tag)
-	t.Name, t.ImageId, t.Namespace, t.Repository, t.Manifest = tag, image, namespace, repository, manifests
+	t.Name, t.ImageId, t.Namespace, t.Repository, t.Manifest, t.MediaType = tag, image, namespace, repository, manifests, mediatype
 
 	if err := t.Save(); err != nil {
 		return err
@@ -272,3 +302,104 @@ func (r *Repository) PutTagFromManifests(image, namespace, repository, tag, mani
 
 	return nil
 }
+
+func (r *Repository) PutBlob(namespace, repository, digest string) error {
+	if _, err := db.Client.SAdd(db.Key("blobs", namespace, repository), digest).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// HasImage reports whether the image is listed by repository, V1 clients list the images before pushing them
+func (r *Repository) HasImage(namespace, repository, imageId string) (bool, error) {
+	if has, _, err := r.Has(namespace, repository); err != nil || has == false || r.JSON == "" {
+		return false, err
+	}
+
+	var images []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(r.JSON), &images); err != nil {
+		return false, err
+	}
+
+	for _, image := range images {
+		if image.ID == imageId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *Repository) HasBlob(namespace, repository, digest string) (bool, error) {
+	return db.Client.SIsMember(db.Key("blobs", namespace, repository), digest).Result()
+}
+
+func (r *Repository) DeleteBlob(namespace, repository, digest string) error {
+	if _, err := db.Client.SRem(db.Key("blobs", namespace, repository), digest).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteTag(namespace, repository, tag string) error {
+	if has, _, err := r.Has(namespace, repository); err != nil {
+		return err
+	} else if has == false {
+		return fmt.Errorf("Repository not found")
+	}
+
+	t := new(Tag)
+	if err := t.Get(namespace, repository, tag); err != nil {
+		return err
+	}
+
+	if err := t.Delete(); err != nil {
+		return err
+	}
+
+	tags := []string{}
+	for _, v := range r.Tags {
+		if v != db.Key("tag", namespace, repository, tag) {
+			tags = append(tags, v)
+		}
+	}
+	r.Tags = tags
+
+	if err := r.Save(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// List returns the names of all repositories in namespace/repository form
+func (r *Repository) List() ([]string, error) {
+	return db.Client.HKeys(db.GLOBAL_REPOSITORY_INDEX).Result()
+}
+
+// List returns all tags of every repository
+func (t *Tag) List() ([]Tag, error) {
+	keys, err := db.Client.HVals(db.GLOBAL_TAG_INDEX).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []Tag{}
+	for _, key := range keys {
+		tag := Tag{}
+		if err := db.Get(&tag, key); err != nil {
+			if err == redis.Nil {
+				continue
+			}
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
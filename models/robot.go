@@ -0,0 +1,135 @@
+package models
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/redis.v3"
+
+	"github.com/containerops/wrench/db"
+)
+
+// RobotSeparator joins the namespace and the name of robot into its username, e.g. containerops+ci
+const RobotSeparator = "+"
+
+// Robot is an account of namespace which isn't a person, it logs in with its token
+// and is granted the permission level on the repositories of namespace.
+type Robot struct {
+	Namespace string `json:"namespace"` //
+	Name      string `json:"name"`      //
+	Username  string `json:"username"`  // namespace+name
+	Level     string `json:"level"`     // permission on repositories of namespace
+	Token     string `json:"token"`     // sha256 hash of token
+	Creator   string `json:"creator"`   //
+	Created   int64  `json:"created"`   //
+	LastUsed  int64  `json:"last_used"` // 0 when never used
+}
+
+// SplitRobotName returns the namespace and the name of robot username, ok is false when it isn't a robot
+func SplitRobotName(username string) (namespace, name string, ok bool) {
+	parts := strings.SplitN(username, RobotSeparator, 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+func (r *Robot) Has(namespace, name string) (bool, string, error) {
+	if key := db.Key("robot", namespace, name); len(key) <= 0 {
+		return false, "", fmt.Errorf("Invalid robot key")
+	} else {
+		if err := db.Get(r, key); err != nil {
+			if err == redis.Nil {
+				return false, "", nil
+			} else {
+				return false, "", err
+			}
+		}
+
+		return true, key, nil
+	}
+}
+
+func (r *Robot) Save() error {
+	key := db.Key("robot", r.Namespace, r.Name)
+
+	if err := db.Save(r, key); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.SAdd(db.Key("robots", r.Namespace), r.Name).Result(); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HSet(db.GLOBAL_ROBOT_INDEX, r.Username, key).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Put creates a robot of namespace, the token is returned and only its hash is saved
+func (r *Robot) Put(namespace, name, level, creator string) (string, error) {
+	token, hash, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+
+	r.Namespace, r.Name, r.Username = namespace, name, namespace+RobotSeparator+name
+	r.Level, r.Token, r.Creator = level, hash, creator
+	r.Created = time.Now().UnixNano() / int64(time.Millisecond)
+
+	return token, r.Save()
+}
+
+// Delete revokes the robot
+func (r *Robot) Delete() error {
+	if _, err := db.Client.Del(db.Key("robot", r.Namespace, r.Name)).Result(); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.SRem(db.Key("robots", r.Namespace), r.Name).Result(); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HDel(db.GLOBAL_ROBOT_INDEX, r.Username).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Verify reports whether token is the one of robot
+func (r *Robot) Verify(token string) bool {
+	return r.Token != "" && subtle.ConstantTimeCompare([]byte(r.Token), []byte(hashSecret(token))) == 1
+}
+
+// Used records the time the robot logs in
+func (r *Robot) Used() error {
+	r.LastUsed = time.Now().UnixNano() / int64(time.Millisecond)
+
+	return db.Save(r, db.Key("robot", r.Namespace, r.Name))
+}
+
+// ListRobots returns the robots of namespace
+func ListRobots(namespace string) ([]Robot, error) {
+	names, err := db.Client.SMembers(db.Key("robots", namespace)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	robots := []Robot{}
+	for _, name := range names {
+		r := new(Robot)
+		if has, _, err := r.Has(namespace, name); err != nil {
+			return nil, err
+		} else if has == true {
+			robots = append(robots, *r)
+		}
+	}
+
+	return robots, nil
+}
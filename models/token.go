@@ -0,0 +1,161 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"gopkg.in/redis.v3"
+
+	"github.com/containerops/wrench/db"
+)
+
+// AccessToken is a long-lived secret of user which logs in instead of the password,
+// it's limited to the actions in scopes and expires.
+type AccessToken struct {
+	ID       string   `json:"id"`        //
+	Username string   `json:"username"`  //
+	Name     string   `json:"name"`      // what the token is used for, e.g. jenkins
+	Scopes   []string `json:"scopes"`    // actions allowed: pull, push, delete
+	Token    string   `json:"token"`     // sha256 hash of token
+	Created  int64    `json:"created"`   //
+	Expires  int64    `json:"expires"`   //
+	LastUsed int64    `json:"last_used"` // 0 when never used
+}
+
+// newSecret returns a random token and its hash, only the hash is saved
+func newSecret() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	token := hex.EncodeToString(secret)
+
+	return token, hashSecret(token), nil
+}
+
+// hashSecret returns the sha256 hash of token, the tokens are random so they aren't salted
+func hashSecret(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (t *AccessToken) Has(username, id string) (bool, string, error) {
+	if key := db.Key("token", username, id); len(key) <= 0 {
+		return false, "", fmt.Errorf("Invalid token key")
+	} else {
+		if err := db.Get(t, key); err != nil {
+			if err == redis.Nil {
+				return false, "", nil
+			} else {
+				return false, "", err
+			}
+		}
+
+		return true, key, nil
+	}
+}
+
+func (t *AccessToken) Save() error {
+	key := db.Key("token", t.Username, t.ID)
+
+	if err := db.Save(t, key); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.SAdd(db.Key("tokens", t.Username), t.ID).Result(); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HSet(db.GLOBAL_TOKEN_INDEX, t.Token, key).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Put creates a token of user which expires after the duration, the token is returned and only its hash is saved
+func (t *AccessToken) Put(username, name string, scopes []string, expiration time.Duration) (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	token, hash, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	t.ID, t.Username, t.Name, t.Scopes, t.Token = hex.EncodeToString(id), username, name, scopes, hash
+	t.Created = now.UnixNano() / int64(time.Millisecond)
+	t.Expires = now.Add(expiration).UnixNano() / int64(time.Millisecond)
+
+	return token, t.Save()
+}
+
+// Delete revokes the token
+func (t *AccessToken) Delete() error {
+	if _, err := db.Client.Del(db.Key("token", t.Username, t.ID)).Result(); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.SRem(db.Key("tokens", t.Username), t.ID).Result(); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HDel(db.GLOBAL_TOKEN_INDEX, t.Token).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Verify loads the token of user by its hash, expired tokens aren't accepted
+func (t *AccessToken) Verify(username, token string) (bool, error) {
+	key, err := db.Client.HGet(db.GLOBAL_TOKEN_INDEX, hashSecret(token)).Result()
+	if err == redis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := db.Get(t, key); err == redis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return t.Username == username && time.Now().UnixNano()/int64(time.Millisecond) < t.Expires, nil
+}
+
+// Used records the time the token is used
+func (t *AccessToken) Used() error {
+	t.LastUsed = time.Now().UnixNano() / int64(time.Millisecond)
+
+	return db.Save(t, db.Key("token", t.Username, t.ID))
+}
+
+// ListAccessTokens returns the tokens of user
+func ListAccessTokens(username string) ([]AccessToken, error) {
+	ids, err := db.Client.SMembers(db.Key("tokens", username)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []AccessToken{}
+	for _, id := range ids {
+		t := new(AccessToken)
+		if has, _, err := t.Has(username, id); err != nil {
+			return nil, err
+		} else if has == true {
+			tokens = append(tokens, *t)
+		}
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/redis.v3"
+
+	"github.com/containerops/wrench/db"
+)
+
+type Upload struct {
+	UUID       string `json:"uuid"`       //
+	Namespace  string `json:"namespace"`  //
+	Repository string `json:"repository"` //
+	Path       string `json:"path"`       // temporary layer file of the upload session
+	Offset     int64  `json:"offset"`     // bytes received so far
+	Started    int64  `json:"started"`    //
+	Updated    int64  `json:"updated"`    //
+}
+
+func (u *Upload) Has(uuid string) (bool, string, error) {
+	if key := db.Key("upload", uuid); len(key) <= 0 {
+		return false, "", fmt.Errorf("Invalid upload key")
+	} else {
+		if err := db.Get(u, key); err != nil {
+			if err == redis.Nil {
+				return false, "", nil
+			} else {
+				return false, "", err
+			}
+		}
+
+		return true, key, nil
+	}
+}
+
+func (u *Upload) Save() error {
+	key := db.Key("upload", u.UUID)
+
+	if err := db.Save(u, key); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HSet(db.GLOBAL_UPLOAD_INDEX, u.UUID, key).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *Upload) Put(uuid, namespace, repository, path string) error {
+	u.UUID, u.Namespace, u.Repository, u.Path, u.Offset = uuid, namespace, repository, path, 0
+	u.Started = time.Now().UnixNano() / int64(time.Millisecond)
+	u.Updated = u.Started
+
+	if err := u.Save(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *Upload) PutOffset(uuid string, offset int64) error {
+	if has, _, err := u.Has(uuid); err != nil {
+		return err
+	} else if has == false {
+		return fmt.Errorf("Upload not found")
+	}
+
+	u.Offset, u.Updated = offset, time.Now().UnixNano()/int64(time.Millisecond)
+
+	if err := u.Save(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *Upload) Delete(uuid string) error {
+	if _, err := db.Client.Del(db.Key("upload", uuid)).Result(); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HDel(db.GLOBAL_UPLOAD_INDEX, uuid).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *Upload) List() ([]Upload, error) {
+	keys, err := db.Client.HVals(db.GLOBAL_UPLOAD_INDEX).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	uploads := []Upload{}
+	for _, key := range keys {
+		upload := Upload{}
+		if err := db.Get(&upload, key); err != nil {
+			if err == redis.Nil {
+				continue
+			}
+			return nil, err
+		}
+
+		uploads = append(uploads, upload)
+	}
+
+	return uploads, nil
+}
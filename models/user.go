@@ -0,0 +1,260 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/redis.v3"
+
+	"github.com/containerops/wrench/db"
+)
+
+// OwnersTeam is the team created with an organization, its members own the organization
+const OwnersTeam = "owners"
+
+type User struct {
+	Username string `json:"username"` //
+	Email    string `json:"email"`    //
+	Password string `json:"password"` // bcrypt hash of password
+	Created  int64  `json:"created"`  //
+	Updated  int64  `json:"updated"`  //
+}
+
+type Organization struct {
+	Name    string   `json:"name"`    //
+	Owner   string   `json:"owner"`   // user who created the organization
+	Teams   []string `json:"teams"`   // team names
+	Created int64    `json:"created"` //
+	Updated int64    `json:"updated"` //
+}
+
+type Team struct {
+	Organization string   `json:"organization"` //
+	Name         string   `json:"name"`         //
+	Members      []string `json:"members"`      // usernames
+	Created      int64    `json:"created"`      //
+	Updated      int64    `json:"updated"`      //
+}
+
+func (u *User) Has(username string) (bool, string, error) {
+	if key := db.Key("user", username); len(key) <= 0 {
+		return false, "", fmt.Errorf("Invalid user key")
+	} else {
+		if err := db.Get(u, key); err != nil {
+			if err == redis.Nil {
+				return false, "", nil
+			} else {
+				return false, "", err
+			}
+		}
+
+		return true, key, nil
+	}
+}
+
+func (u *User) Save() error {
+	key := db.Key("user", u.Username)
+
+	if err := db.Save(u, key); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HSet(db.GLOBAL_USER_INDEX, u.Username, key).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Put signs up a user, the password is saved as a bcrypt hash
+func (u *User) Put(username, email, password string) error {
+	u.Username, u.Email = username, email
+	u.Created = time.Now().UnixNano() / int64(time.Millisecond)
+
+	return u.PutPassword(password)
+}
+
+// PutPassword changes the password of user
+func (u *User) PutPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	u.Password = string(hash)
+	u.Updated = time.Now().UnixNano() / int64(time.Millisecond)
+
+	return u.Save()
+}
+
+// Verify reports whether password matches the one of user
+func (u *User) Verify(password string) bool {
+	return u.Password != "" && bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
+}
+
+func (o *Organization) Has(name string) (bool, string, error) {
+	if key := db.Key("organization", name); len(key) <= 0 {
+		return false, "", fmt.Errorf("Invalid organization key")
+	} else {
+		if err := db.Get(o, key); err != nil {
+			if err == redis.Nil {
+				return false, "", nil
+			} else {
+				return false, "", err
+			}
+		}
+
+		return true, key, nil
+	}
+}
+
+func (o *Organization) Save() error {
+	key := db.Key("organization", o.Name)
+
+	if err := db.Save(o, key); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HSet(db.GLOBAL_ORGANIZATION_INDEX, o.Name, key).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Put creates an organization with the owners team which owner is the first member of
+func (o *Organization) Put(name, owner string) error {
+	o.Name, o.Owner, o.Teams = name, owner, []string{}
+	o.Created = time.Now().UnixNano() / int64(time.Millisecond)
+	o.Updated = o.Created
+
+	if err := o.Save(); err != nil {
+		return err
+	}
+
+	t := new(Team)
+	if err := t.Put(name, OwnersTeam); err != nil {
+		return err
+	}
+
+	if err := t.PutMember(owner); err != nil {
+		return err
+	}
+
+	//the team is added to the record saved by team
+	_, _, err := o.Has(name)
+	return err
+}
+
+// IsMember reports whether user is a member of any team of organization
+func (o *Organization) IsMember(username string) (bool, error) {
+	for _, name := range o.Teams {
+		t := new(Team)
+		if has, _, err := t.Has(o.Name, name); err != nil {
+			return false, err
+		} else if has == true && t.HasMember(username) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// IsOwner reports whether user is a member of the owners team of organization
+func (o *Organization) IsOwner(username string) (bool, error) {
+	t := new(Team)
+	if has, _, err := t.Has(o.Name, OwnersTeam); err != nil || has == false {
+		return false, err
+	}
+
+	return t.HasMember(username), nil
+}
+
+func (t *Team) Has(organization, name string) (bool, string, error) {
+	if key := db.Key("team", organization, name); len(key) <= 0 {
+		return false, "", fmt.Errorf("Invalid team key")
+	} else {
+		if err := db.Get(t, key); err != nil {
+			if err == redis.Nil {
+				return false, "", nil
+			} else {
+				return false, "", err
+			}
+		}
+
+		return true, key, nil
+	}
+}
+
+func (t *Team) Save() error {
+	key := db.Key("team", t.Organization, t.Name)
+
+	if err := db.Save(t, key); err != nil {
+		return err
+	}
+
+	if _, err := db.Client.HSet(db.GLOBAL_TEAM_INDEX, fmt.Sprintf("%s/%s", t.Organization, t.Name), key).Result(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Put creates a team without members and adds it to the organization
+func (t *Team) Put(organization, name string) error {
+	o := new(Organization)
+	if has, _, err := o.Has(organization); err != nil {
+		return err
+	} else if has == false {
+		return fmt.Errorf("Organization not found")
+	}
+
+	t.Organization, t.Name, t.Members = organization, name, []string{}
+	t.Created = time.Now().UnixNano() / int64(time.Millisecond)
+	t.Updated = t.Created
+
+	if err := t.Save(); err != nil {
+		return err
+	}
+
+	o.Teams = append(o.Teams, name)
+	o.Updated = t.Created
+
+	return o.Save()
+}
+
+func (t *Team) HasMember(username string) bool {
+	for _, member := range t.Members {
+		if member == username {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Team) PutMember(username string) error {
+	if t.HasMember(username) {
+		return nil
+	}
+
+	t.Members = append(t.Members, username)
+	t.Updated = time.Now().UnixNano() / int64(time.Millisecond)
+
+	return t.Save()
+}
+
+func (t *Team) DeleteMember(username string) error {
+	members := []string{}
+	for _, member := range t.Members {
+		if member != username {
+			members = append(members, member)
+		}
+	}
+
+	t.Members = members
+	t.Updated = time.Now().UnixNano() / int64(time.Millisecond)
+
+	return t.Save()
+}
@@ -0,0 +1,103 @@
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containerops/wrench/setting"
+)
+
+// Blobs of both V1 and V2 API are stored once by content as <ImagePath>/blobs/<algorithm>/<first two hex>/<hex>/<hex>,
+// backend drivers key objects by the file name so it's unique to the blob
+
+// BlobPath returns the path of blob data in store by its digest
+func BlobPath(digest string) (string, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || len(parts[1]) < 2 || DigestRegexp.FindString(digest) != digest {
+		return "", fmt.Errorf("Invalid blob digest: %v", digest)
+	}
+
+	return filepath.Join(setting.ImagePath, "blobs", parts[0], parts[1][:2], parts[1], parts[1]), nil
+}
+
+// IsBlobPath reports whether the layer file is already kept in blob store
+func IsBlobPath(path string) bool {
+	return strings.HasPrefix(path, filepath.Join(setting.ImagePath, "blobs")+string(filepath.Separator))
+}
+
+// TouchBlob refreshes the modify time of blob, which keeps it from garbage collection within grace period
+// while clients checking or mounting it push the manifest referencing it.
+func TouchBlob(digest string) {
+	if path, err := BlobPath(digest); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+	}
+}
+
+// CommitBlob moves a verified file into blob store and returns its path and size,
+// the file is dropped when the same content has been stored.
+func CommitBlob(src, digest string) (string, int64, error) {
+	dst, err := BlobPath(digest)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if info, err := os.Stat(dst); err == nil {
+		os.Remove(src)
+		TouchBlob(digest)
+		return dst, info.Size(), nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return "", 0, err
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		return "", 0, err
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return dst, info.Size(), nil
+}
+
+// StoreBlob writes the content of reader into blob store by its sha256 digest and returns the digest, path and size
+func StoreBlob(reader io.Reader) (string, string, int64, error) {
+	tmp := filepath.Join(setting.ImagePath, "blobs", "tmp")
+	if err := os.MkdirAll(tmp, os.ModePerm); err != nil {
+		return "", "", 0, err
+	}
+
+	fd, err := ioutil.TempFile(tmp, "blob")
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(fd, hash), reader); err != nil {
+		fd.Close()
+		os.Remove(fd.Name())
+		return "", "", 0, err
+	}
+	fd.Close()
+
+	digest := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+
+	path, size, err := CommitBlob(fd.Name(), digest)
+	if err != nil {
+		os.Remove(fd.Name())
+		return "", "", 0, err
+	}
+
+	return digest, path, size, nil
+}
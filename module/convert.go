@@ -0,0 +1,276 @@
+package module
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/containerops/dockyard/models"
+)
+
+// digest and content of a gzipped empty tar, which stands for the empty layers of schema2 history in schema1
+const EmptyLayerDigest = "sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4"
+
+var emptyLayer = []byte{
+	31, 139, 8, 0, 0, 9, 110, 136, 0, 255, 98, 24, 5, 163, 96, 20, 140, 88,
+	0, 8, 0, 0, 255, 255, 46, 175, 181, 239, 0, 4, 0, 0,
+}
+
+type ManifestV1 struct {
+	SchemaVersion int         `json:"schemaVersion"`
+	Name          string      `json:"name"`
+	Tag           string      `json:"tag"`
+	Architecture  string      `json:"architecture"`
+	FSLayers      []FSLayer   `json:"fsLayers"`
+	History       []HistoryV1 `json:"history"`
+}
+
+type FSLayer struct {
+	BlobSum string `json:"blobSum"`
+}
+
+type HistoryV1 struct {
+	V1Compatibility string `json:"v1Compatibility"`
+}
+
+// ImageConfig is the part of schema2 config blob used to build the schema1 history
+type ImageConfig struct {
+	Architecture string         `json:"architecture"`
+	History      []ImageHistory `json:"history"`
+}
+
+type ImageHistory struct {
+	Created    string `json:"created,omitempty"`
+	CreatedBy  string `json:"created_by,omitempty"`
+	Author     string `json:"author,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	EmptyLayer bool   `json:"empty_layer,omitempty"`
+}
+
+type v1Compatibility struct {
+	ID              string `json:"id"`
+	Parent          string `json:"parent,omitempty"`
+	Comment         string `json:"comment,omitempty"`
+	Created         string `json:"created"`
+	ContainerConfig struct {
+		Cmd []string
+	} `json:"container_config,omitempty"`
+	Author    string `json:"author,omitempty"`
+	ThrowAway bool   `json:"throwaway,omitempty"`
+}
+
+// ManifestUnconvertibleError is returned when an OCI manifest isn't an image which schema1 clients pull, e.g. an artifact
+type ManifestUnconvertibleError struct {
+	Reason string
+}
+
+func (e ManifestUnconvertibleError) Error() string {
+	return fmt.Sprintf("Manifest can't be converted to schema1: %v", e.Reason)
+}
+
+// ConvertManifestV1 builds a signed schema1 manifest for clients which understand neither schema2 nor OCI manifest,
+// the history and image ids are generated from the config blob the same way as docker distribution does.
+func ConvertManifestV1(data []byte, mediatype, namespace, repository, tag string) ([]byte, error) {
+	var manifest ManifestV2
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	if mediatype == OCIManifestMediaType {
+		if err := convertibleOCI(manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := readBlob(manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	v1, empty, err := buildManifestV1(manifest, raw, fmt.Sprintf("%s/%s", namespace, repository), tag)
+	if err != nil {
+		return nil, err
+	}
+
+	//old clients pull the empty layer like any other blob of repository
+	if empty {
+		if err := putEmptyLayer(namespace, repository); err != nil {
+			return nil, err
+		}
+	}
+
+	payload, err := json.MarshalIndent(v1, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+
+	return SignManifest(payload)
+}
+
+// convertibleOCI checks the OCI manifest is an image with layers which old clients extract like schema2 layers
+func convertibleOCI(manifest ManifestV2) error {
+	if manifest.Config.MediaType != OCIImageConfigMediaType {
+		return ManifestUnconvertibleError{Reason: fmt.Sprintf("config %v isn't an image config", manifest.Config.MediaType)}
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != OCILayerMediaType && layer.MediaType != OCIUncompressedLayerMediaType {
+			return ManifestUnconvertibleError{Reason: fmt.Sprintf("layer %v of %v isn't pulled by schema1 clients", layer.Digest, layer.MediaType)}
+		}
+	}
+
+	return nil
+}
+
+// buildManifestV1 generates the schema1 layers and history from the schema2 manifest and its config blob,
+// and reports whether the empty layer is referenced.
+func buildManifestV1(manifest ManifestV2, raw []byte, name, tag string) (v1 ManifestV1, empty bool, err error) {
+	var config ImageConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return v1, false, err
+	}
+
+	//images built without history get one anonymous entry per layer
+	history := config.History
+	if len(history) == 0 {
+		history = make([]ImageHistory, len(manifest.Layers))
+	}
+
+	layers := 0
+	for _, h := range history {
+		if !h.EmptyLayer {
+			layers++
+		}
+	}
+	if layers != len(manifest.Layers) {
+		return v1, false, fmt.Errorf("Config history has %v layers but manifest has %v", layers, len(manifest.Layers))
+	}
+
+	v1 = ManifestV1{
+		SchemaVersion: 1,
+		Name:          name,
+		Tag:           tag,
+		Architecture:  config.Architecture,
+		FSLayers:      make([]FSLayer, len(history)),
+		History:       make([]HistoryV1, len(history)),
+	}
+
+	//schema1 lists layers from top to base, so entries are filled backwards
+	parent, index := "", 0
+	for i, h := range history {
+		blobsum := EmptyLayerDigest
+		if h.EmptyLayer {
+			empty = true
+		} else {
+			blobsum = manifest.Layers[index].Digest
+			index++
+		}
+
+		var compatibility []byte
+		var id string
+		if i == len(history)-1 {
+			//top layer carries the image config itself, with history and rootfs stripped
+			id = v1ID(blobsum, parent+" "+string(raw))
+
+			var image map[string]interface{}
+			if err := json.Unmarshal(raw, &image); err != nil {
+				return v1, false, err
+			}
+
+			delete(image, "history")
+			delete(image, "rootfs")
+			image["id"] = id
+			if parent != "" {
+				image["parent"] = parent
+			}
+			if h.EmptyLayer {
+				image["throwaway"] = true
+			}
+
+			if compatibility, err = json.Marshal(image); err != nil {
+				return v1, false, err
+			}
+		} else {
+			id = v1ID(blobsum, parent)
+
+			c := v1Compatibility{ID: id, Parent: parent, Comment: h.Comment, Created: h.Created, Author: h.Author, ThrowAway: h.EmptyLayer}
+			c.ContainerConfig.Cmd = []string{h.CreatedBy}
+
+			if compatibility, err = json.Marshal(c); err != nil {
+				return v1, false, err
+			}
+		}
+
+		v1.FSLayers[len(history)-i-1] = FSLayer{BlobSum: blobsum}
+		v1.History[len(history)-i-1] = HistoryV1{V1Compatibility: string(compatibility)}
+
+		parent = id
+	}
+
+	return v1, empty, nil
+}
+
+// v1ID generates the v1 image id of a layer from its blobsum and what comes below it
+func v1ID(blobsum, parent string) string {
+	hash := sha256.Sum256([]byte(strings.TrimPrefix(blobsum, "sha256:") + " " + parent))
+	return hex.EncodeToString(hash[:])
+}
+
+// readBlob reads a blob from local storage, or from the backend storage url when the local one is gone
+func readBlob(digest string) ([]byte, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid blob digest: %v", digest)
+	}
+
+	i := new(models.Image)
+	if has, _ := i.HasTarsum(parts[1]); has == false {
+		return nil, BlobUnknownError{Digest: digest}
+	}
+
+	if data, err := ioutil.ReadFile(i.Path); err == nil {
+		return data, nil
+	} else if i.URL == "" {
+		return nil, err
+	}
+
+	resp, err := http.Get(i.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Read blob %v from backend failed: %v", digest, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// putEmptyLayer stores the empty layer once and links it to repository
+func putEmptyLayer(namespace, repository string) error {
+	tarsum := strings.TrimPrefix(EmptyLayerDigest, "sha256:")
+
+	i := new(models.Image)
+	if has, _ := i.HasTarsum(tarsum); has == false {
+		digest, layerfile, size, err := StoreBlob(bytes.NewReader(emptyLayer))
+		if err != nil {
+			return err
+		} else if digest != EmptyLayerDigest {
+			return fmt.Errorf("Empty layer digest mismatch: %v", digest)
+		}
+
+		i.Path, i.Size = layerfile, size
+		if err := i.PutTarsum(tarsum); err != nil {
+			return err
+		}
+	}
+
+	r := new(models.Repository)
+	return r.PutBlob(namespace, repository, EmptyLayerDigest)
+}
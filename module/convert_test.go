@@ -0,0 +1,198 @@
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func Test_buildManifestV1(t *testing.T) {
+	base, app := sha256Digest([]byte("base")), sha256Digest([]byte("app"))
+	layers := []Descriptor{{MediaType: LayerMediaType, Digest: base}, {MediaType: LayerMediaType, Digest: app}}
+
+	cases := []struct {
+		name    string
+		config  string
+		layers  []Descriptor
+		blobs   []string // fsLayers from top to base
+		cmds    []string // created_by of history from top to base, the top one carries config
+		empty   bool
+		invalid bool
+	}{
+		{
+			name:   "history with empty layers",
+			config: `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers"},"history":[{"created_by":"ADD base"},{"created_by":"ENV A=1","empty_layer":true},{"created_by":"COPY app"},{"created_by":"CMD app","empty_layer":true}]}`,
+			layers: layers,
+			blobs:  []string{EmptyLayerDigest, app, EmptyLayerDigest, base},
+			cmds:   []string{"", "COPY app", "ENV A=1", "ADD base"},
+			empty:  true,
+		},
+		{
+			name:   "history without empty layers",
+			config: `{"architecture":"arm64","history":[{"created_by":"ADD base"},{"created_by":"COPY app"}]}`,
+			layers: layers,
+			blobs:  []string{app, base},
+			cmds:   []string{"", "ADD base"},
+		},
+		{
+			name:   "no history",
+			config: `{"architecture":"amd64"}`,
+			layers: layers,
+			blobs:  []string{app, base},
+			cmds:   []string{"", ""},
+		},
+		{
+			name:    "history has fewer layers than manifest",
+			config:  `{"architecture":"amd64","history":[{"created_by":"ADD base"},{"created_by":"CMD app","empty_layer":true}]}`,
+			layers:  layers,
+			invalid: true,
+		},
+		{
+			name:    "history has more layers than manifest",
+			config:  `{"architecture":"amd64","history":[{"created_by":"ADD base"},{"created_by":"COPY app"}]}`,
+			layers:  layers[:1],
+			invalid: true,
+		},
+		{
+			name:    "invalid config",
+			config:  `{"architecture":`,
+			layers:  layers,
+			invalid: true,
+		},
+	}
+
+	for _, c := range cases {
+		manifest := ManifestV2{SchemaVersion: 2, MediaType: ManifestV2MediaType, Layers: c.layers}
+
+		v1, empty, err := buildManifestV1(manifest, []byte(c.config), "library/busybox", "latest")
+		if c.invalid {
+			if err == nil {
+				t.Errorf("%v: expected error", c.name)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%v: %v", c.name, err)
+			continue
+		}
+
+		if empty != c.empty {
+			t.Errorf("%v: expected empty layer referenced %v, got %v", c.name, c.empty, empty)
+		}
+
+		if v1.SchemaVersion != 1 || v1.Name != "library/busybox" || v1.Tag != "latest" {
+			t.Errorf("%v: unexpected manifest %v:%v schema %v", c.name, v1.Name, v1.Tag, v1.SchemaVersion)
+		}
+
+		if len(v1.FSLayers) != len(c.blobs) || len(v1.History) != len(c.blobs) {
+			t.Errorf("%v: expected %v fsLayers and history, got %v and %v", c.name, len(c.blobs), len(v1.FSLayers), len(v1.History))
+			continue
+		}
+
+		images := make([]map[string]interface{}, len(v1.History))
+		for i, h := range v1.History {
+			if err := json.Unmarshal([]byte(h.V1Compatibility), &images[i]); err != nil {
+				t.Fatalf("%v: history %v: %v", c.name, i, err)
+			}
+		}
+
+		for i := range c.blobs {
+			if v1.FSLayers[i].BlobSum != c.blobs[i] {
+				t.Errorf("%v: expected fsLayer %v to be %v, got %v", c.name, i, c.blobs[i], v1.FSLayers[i].BlobSum)
+			}
+
+			//every entry is the parent of the one above it and the base has none
+			parent := ""
+			if i < len(c.blobs)-1 {
+				parent, _ = images[i+1]["id"].(string)
+			}
+			if p, _ := images[i]["parent"].(string); p != parent {
+				t.Errorf("%v: expected history %v parent %q, got %q", c.name, i, parent, p)
+			}
+
+			if throwaway, _ := images[i]["throwaway"].(bool); throwaway != (c.blobs[i] == EmptyLayerDigest && c.empty) {
+				t.Errorf("%v: history %v of %v has throwaway %v", c.name, i, c.blobs[i], throwaway)
+			}
+
+			if i == 0 {
+				continue
+			}
+
+			expected := v1ID(c.blobs[i], parent)
+			if images[i]["id"] != expected {
+				t.Errorf("%v: expected history %v id %v, got %v", c.name, i, expected, images[i]["id"])
+			}
+
+			cmd := ""
+			if config, ok := images[i]["container_config"].(map[string]interface{}); ok {
+				if cmds, ok := config["Cmd"].([]interface{}); ok && len(cmds) == 1 {
+					cmd, _ = cmds[0].(string)
+				}
+			}
+			if cmd != c.cmds[i] {
+				t.Errorf("%v: expected history %v created by %q, got %q", c.name, i, c.cmds[i], cmd)
+			}
+		}
+
+		//the top entry is the config itself without history and rootfs
+		top := images[0]
+		if top["architecture"] == nil || top["architecture"] != v1.Architecture {
+			t.Errorf("%v: expected config in top history, got %v", c.name, v1.History[0].V1Compatibility)
+		}
+		if _, has := top["history"]; has {
+			t.Errorf("%v: expected history stripped from top history", c.name)
+		}
+		if _, has := top["rootfs"]; has {
+			t.Errorf("%v: expected rootfs stripped from top history", c.name)
+		}
+
+		parent, _ := top["parent"].(string)
+		if expected := v1ID(c.blobs[0], parent+" "+c.config); top["id"] != expected {
+			t.Errorf("%v: expected top history id %v, got %v", c.name, expected, top["id"])
+		}
+	}
+}
+
+func Test_EmptyLayerDigest(t *testing.T) {
+	if digest := fmt.Sprintf("sha256:%x", sha256.Sum256(emptyLayer)); digest != EmptyLayerDigest {
+		t.Errorf("Expected empty layer digested as %v, got %v", EmptyLayerDigest, digest)
+	}
+}
+
+func Test_ConvertManifestV1OCI(t *testing.T) {
+	config := Descriptor{MediaType: OCIImageConfigMediaType, Digest: sha256Digest([]byte("config"))}
+	layer := func(mediatype string) Descriptor {
+		return Descriptor{MediaType: mediatype, Digest: sha256Digest([]byte(mediatype))}
+	}
+
+	cases := []struct {
+		name        string
+		manifest    ManifestV2
+		convertible bool
+	}{
+		{"image", ManifestV2{Config: config, Layers: []Descriptor{layer(OCILayerMediaType), layer(OCIUncompressedLayerMediaType)}}, true},
+		{"artifact", ManifestV2{Config: Descriptor{MediaType: "application/vnd.cncf.helm.config.v1+json", Digest: config.Digest}}, false},
+		{"zstd layer", ManifestV2{Config: config, Layers: []Descriptor{layer("application/vnd.oci.image.layer.v1.tar+zstd")}}, false},
+		{"foreign layer", ManifestV2{Config: config, Layers: []Descriptor{layer(OCIForeignLayerMediaType)}}, false},
+	}
+
+	for _, c := range cases {
+		c.manifest.SchemaVersion = 2
+		data, _ := json.Marshal(c.manifest)
+
+		if err := convertibleOCI(c.manifest); (err == nil) != c.convertible {
+			t.Errorf("%v: expected convertible %v, got %v", c.name, c.convertible, err)
+		}
+
+		//images go on to read their config blob
+		if c.convertible {
+			continue
+		}
+
+		if _, err := ConvertManifestV1(data, OCIManifestMediaType, "library", "busybox", "latest"); err == nil {
+			t.Errorf("%v: expected manifest not converted", c.name)
+		} else if _, ok := err.(ManifestUnconvertibleError); !ok {
+			t.Errorf("%v: expected manifest unconvertible, got %v", c.name, err)
+		}
+	}
+}
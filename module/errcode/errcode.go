@@ -0,0 +1,64 @@
+package errcode
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorCode is an error defined by the Docker Registry HTTP API V2 with its HTTP status
+type ErrorCode struct {
+	Value          string
+	Message        string
+	HTTPStatusCode int
+}
+
+var (
+	Unknown                 = ErrorCode{"UNKNOWN", "unknown error", http.StatusInternalServerError}
+	Unsupported             = ErrorCode{"UNSUPPORTED", "The operation is unsupported.", http.StatusMethodNotAllowed}
+	Unauthorized            = ErrorCode{"UNAUTHORIZED", "authentication required", http.StatusUnauthorized}
+	Denied                  = ErrorCode{"DENIED", "requested access to the resource is denied", http.StatusForbidden}
+	TooManyRequests         = ErrorCode{"TOOMANYREQUESTS", "too many requests", http.StatusTooManyRequests}
+	BlobUnknown             = ErrorCode{"BLOB_UNKNOWN", "blob unknown to registry", http.StatusNotFound}
+	BlobUploadInvalid       = ErrorCode{"BLOB_UPLOAD_INVALID", "blob upload invalid", http.StatusBadRequest}
+	BlobUploadUnknown       = ErrorCode{"BLOB_UPLOAD_UNKNOWN", "blob upload unknown to registry", http.StatusNotFound}
+	DigestInvalid           = ErrorCode{"DIGEST_INVALID", "provided digest did not match uploaded content", http.StatusBadRequest}
+	ManifestBlobUnknown     = ErrorCode{"MANIFEST_BLOB_UNKNOWN", "blob unknown to registry", http.StatusBadRequest}
+	ManifestInvalid         = ErrorCode{"MANIFEST_INVALID", "manifest invalid", http.StatusBadRequest}
+	ManifestUnknown         = ErrorCode{"MANIFEST_UNKNOWN", "manifest unknown", http.StatusNotFound}
+	ManifestUnverified      = ErrorCode{"MANIFEST_UNVERIFIED", "manifest failed signature verification", http.StatusBadRequest}
+	NameInvalid             = ErrorCode{"NAME_INVALID", "invalid repository name", http.StatusBadRequest}
+	NameUnknown             = ErrorCode{"NAME_UNKNOWN", "repository name not known to registry", http.StatusNotFound}
+	SizeInvalid             = ErrorCode{"SIZE_INVALID", "provided length did not match content length", http.StatusBadRequest}
+	TagInvalid              = ErrorCode{"TAG_INVALID", "manifest tag did not match URI", http.StatusBadRequest}
+	RangeInvalid            = ErrorCode{"RANGE_INVALID", "invalid content range", http.StatusRequestedRangeNotSatisfiable}
+	PaginationNumberInvalid = ErrorCode{"PAGINATION_NUMBER_INVALID", "invalid number of results requested", http.StatusBadRequest}
+)
+
+type Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Detail  interface{} `json:"detail,omitempty"`
+}
+
+type Errors struct {
+	Errors []Error `json:"errors"`
+}
+
+func (c ErrorCode) Error() string {
+	return c.Message
+}
+
+// Response returns the HTTP status of code and the error envelope with detail, a nil detail is omitted
+func (c ErrorCode) Response(detail interface{}) (int, []byte) {
+	result, _ := json.Marshal(Errors{Errors: []Error{{Code: c.Value, Message: c.Message, Detail: detail}}})
+	return c.HTTPStatusCode, result
+}
+
+// Write writes the error envelope to handlers which stream their response
+func (c ErrorCode) Write(w http.ResponseWriter, detail interface{}) {
+	status, result := c.Response(detail)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(result)
+}
@@ -0,0 +1,28 @@
+package errcode
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func Test_response(t *testing.T) {
+	status, result := BlobUnknown.Response("sha256:abc")
+	if status != http.StatusNotFound {
+		t.Errorf("Expected status %v, got %v", http.StatusNotFound, status)
+	}
+
+	var errs Errors
+	if err := json.Unmarshal(result, &errs); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(errs.Errors) != 1 || errs.Errors[0].Code != "BLOB_UNKNOWN" || errs.Errors[0].Detail != "sha256:abc" {
+		t.Errorf("Unexpected error envelope: %s", result)
+	}
+
+	if _, result := Unsupported.Response(nil); string(result) != `{"errors":[{"code":"UNSUPPORTED","message":"The operation is unsupported."}]}` {
+		t.Errorf("Unexpected error envelope: %s", result)
+	}
+}
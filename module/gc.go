@@ -0,0 +1,241 @@
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+
+	"github.com/containerops/dockyard/backend/drivers"
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/wrench/setting"
+)
+
+// GarbageCollect removes blobs which are referenced by neither tags nor manifests from blob store and backend storage.
+// Blobs and temporary files modified within grace period are kept for in-flight uploads,
+// and nothing is removed but only reported when dryrun is set.
+func GarbageCollect(grace time.Duration, dryrun bool, log *logs.BeeLogger) error {
+	//blobs removed locally but kept in backend storage would never be found again
+	if !dryrun && !drivers.Deletable() {
+		return fmt.Errorf("Backend driver %v can't delete blobs", setting.BackendDriver)
+	}
+
+	marked, err := markBlobs()
+	if err != nil {
+		return err
+	}
+
+	//the empty layer is written by schema1 conversion and referenced by no stored manifest
+	markDigest(EmptyLayerDigest, marked)
+
+	log.Info("[GC] Marked %v blobs in use", len(marked))
+
+	r := new(models.Repository)
+	repositories, err := r.List()
+	if err != nil {
+		return err
+	}
+
+	root := filepath.Join(setting.ImagePath, "blobs")
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+
+	deadline := time.Now().Add(-grace)
+	count, reclaimed := 0, int64(0)
+
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || info.ModTime().After(deadline) {
+			return nil
+		}
+
+		//temporary files left by interrupted uploads
+		if filepath.Base(filepath.Dir(path)) == "tmp" {
+			if dryrun {
+				log.Info("[GC] Temporary file %v would be removed, %v bytes", path, info.Size())
+			} else if err := os.Remove(path); err != nil {
+				log.Error("[GC] Remove temporary file %v failed: %v", path, err.Error())
+				return nil
+			}
+
+			count, reclaimed = count+1, reclaimed+info.Size()
+			return nil
+		}
+
+		hex := filepath.Base(filepath.Dir(path))
+		if info.Name() != hex || marked[hex] {
+			return nil
+		}
+
+		digest := fmt.Sprintf("%v:%v", filepath.Base(filepath.Dir(filepath.Dir(filepath.Dir(path)))), hex)
+
+		//the blob may be checked or mounted by a push after it's marked, which refreshes its modify time
+		if latest, err := os.Stat(path); err != nil || latest.ModTime().After(deadline) {
+			return nil
+		}
+
+		if dryrun {
+			log.Info("[GC] Blob %v would be removed, %v bytes", digest, info.Size())
+		} else if err := sweepBlob(digest, path, repositories, log); err != nil {
+			log.Error("[GC] Remove blob %v failed: %v", digest, err.Error())
+			return nil
+		} else {
+			log.Info("[GC] Removed blob %v, %v bytes", digest, info.Size())
+		}
+
+		count, reclaimed = count+1, reclaimed+info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if dryrun {
+		log.Info("[GC] Dry run: %v files would be removed, %v bytes would be reclaimed", count, reclaimed)
+	} else {
+		log.Info("[GC] %v files removed, %v bytes reclaimed", count, reclaimed)
+	}
+
+	return nil
+}
+
+// StartGC runs GarbageCollect every interval in background, a zero interval disables it.
+func StartGC(grace, interval time.Duration, log *logs.BeeLogger) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		for {
+			time.Sleep(interval)
+
+			if err := GarbageCollect(grace, false, log); err != nil {
+				log.Error("[GC] Garbage collect failed: %v", err.Error())
+			}
+		}
+	}()
+}
+
+// markBlobs returns the digest hex of all blobs referenced by tags and manifests,
+// it fails on any manifest can't be parsed rather than sweeping blobs in use.
+func markBlobs() (map[string]bool, error) {
+	marked := map[string]bool{}
+
+	t := new(models.Tag)
+	tags, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		if tag.Manifest == "" {
+			//tags pushed through V1 API reference layers by image ancestry
+			if err := markAncestry(tag.ImageId, marked); err != nil {
+				return nil, fmt.Errorf("Mark image %v of tag %v/%v:%v failed: %v", tag.ImageId, tag.Namespace, tag.Repository, tag.Name, err.Error())
+			}
+			continue
+		}
+
+		if err := markManifest([]byte(tag.Manifest), tag.MediaType, marked); err != nil {
+			return nil, fmt.Errorf("Mark manifest of tag %v/%v:%v failed: %v", tag.Namespace, tag.Repository, tag.Name, err.Error())
+		}
+	}
+
+	m := new(models.Manifest)
+	manifests, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, manifest := range manifests {
+		if err := markManifest([]byte(manifest.Manifest), manifest.MediaType, marked); err != nil {
+			return nil, fmt.Errorf("Mark manifest %v/%v@%v failed: %v", manifest.Namespace, manifest.Repository, manifest.Digest, err.Error())
+		}
+	}
+
+	return marked, nil
+}
+
+// markManifest marks the config and layers of an image manifest, children of manifest list are marked by their own records
+func markManifest(data []byte, contentType string, marked map[string]bool) error {
+	blobs, err := ManifestBlobs(data, contentType)
+	if err != nil {
+		return err
+	}
+
+	for _, blob := range blobs {
+		markDigest(blob.Digest, marked)
+	}
+
+	return nil
+}
+
+func markAncestry(imageId string, marked map[string]bool) error {
+	i := new(models.Image)
+	if has, _, err := i.Has(imageId); err != nil {
+		return err
+	} else if has == false {
+		return nil
+	}
+
+	var ancestry []string
+	if err := json.Unmarshal([]byte(i.Ancestry), &ancestry); err != nil {
+		ancestry = []string{imageId}
+	}
+
+	for _, id := range ancestry {
+		image := new(models.Image)
+		if has, _, err := image.Has(id); err != nil {
+			return err
+		} else if has == true && IsBlobPath(image.Path) {
+			marked[filepath.Base(filepath.Dir(image.Path))] = true
+		}
+	}
+
+	return nil
+}
+
+func markDigest(digest string, marked map[string]bool) {
+	if parts := strings.Split(digest, ":"); len(parts) > 1 {
+		marked[parts[len(parts)-1]] = true
+	}
+}
+
+// sweepBlob removes the blob from backend storage and blob store, then unlinks it from all repositories,
+// the blob is kept for the next run when backend storage fails to remove it
+func sweepBlob(digest, path string, repositories []string, log *logs.BeeLogger) error {
+	tarsum := filepath.Base(filepath.Dir(path))
+
+	i := new(models.Image)
+	if has, _ := i.HasTarsum(tarsum); has == true && i.URL != "" {
+		if err := drivers.Delete(i.Path); err != nil {
+			return fmt.Errorf("Remove from backend %v failed: %v", setting.BackendDriver, err.Error())
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	if err := i.DeleteTarsum(tarsum); err != nil {
+		return err
+	}
+
+	r := new(models.Repository)
+	for _, name := range repositories {
+		namespace, repository := SplitRepositoryName(name)
+		if err := r.DeleteBlob(namespace, repository, digest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
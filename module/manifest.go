@@ -0,0 +1,518 @@
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/redis.v3"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/wrench/utils"
+)
+
+const (
+	ManifestV1MediaType       = "application/vnd.docker.distribution.manifest.v1+json"
+	SignedManifestV1MediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	ManifestV2MediaType       = "application/vnd.docker.distribution.manifest.v2+json"
+	ManifestListMediaType     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ImageConfigMediaType      = "application/vnd.docker.container.image.v1+json"
+	LayerMediaType            = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	ForeignLayerMediaType     = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+
+	OCIManifestMediaType          = "application/vnd.oci.image.manifest.v1+json"
+	OCIIndexMediaType             = "application/vnd.oci.image.index.v1+json"
+	OCIImageConfigMediaType       = "application/vnd.oci.image.config.v1+json"
+	OCILayerMediaType             = "application/vnd.oci.image.layer.v1.tar+gzip"
+	OCIUncompressedLayerMediaType = "application/vnd.oci.image.layer.v1.tar"
+	OCIForeignLayerMediaType      = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
+)
+
+// default platform served to clients which don't understand manifest list or image index
+const (
+	DefaultPlatformOS           = "linux"
+	DefaultPlatformArchitecture = "amd64"
+)
+
+type Descriptor struct {
+	MediaType    string            `json:"mediaType,omitempty"`
+	Size         int64             `json:"size"`
+	Digest       string            `json:"digest"`
+	URLs         []string          `json:"urls,omitempty"`
+	Platform     *Platform         `json:"platform,omitempty"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type Platform struct {
+	Architecture string   `json:"architecture"`
+	OS           string   `json:"os"`
+	OSVersion    string   `json:"os.version,omitempty"`
+	OSFeatures   []string `json:"os.features,omitempty"`
+	Variant      string   `json:"variant,omitempty"`
+	Features     []string `json:"features,omitempty"`
+}
+
+type ManifestV2 struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+type ManifestList struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// BlobUnknownError is returned when a manifest references a blob or a child manifest which has not been pushed
+type BlobUnknownError struct {
+	Digest string
+}
+
+func (e BlobUnknownError) Error() string {
+	return fmt.Sprintf("Manifest references unknown blob: %v", e.Digest)
+}
+
+// DigestInvalidError is returned when a manifest is pushed by digest which doesn't match its content
+type DigestInvalidError struct {
+	Digest string
+}
+
+func (e DigestInvalidError) Error() string {
+	return fmt.Sprintf("Manifest digest doesn't match: %v", e.Digest)
+}
+
+// IsDigest reports whether the manifest reference is a digest rather than a tag
+func IsDigest(reference string) bool {
+	return strings.Contains(reference, ":") && DigestRegexp.FindString(reference) == reference
+}
+
+// IsManifestList reports whether the media type is a manifest list or an image index
+func IsManifestList(mediatype string) bool {
+	return mediatype == ManifestListMediaType || mediatype == OCIIndexMediaType
+}
+
+// AcceptsMediaType reports whether the media type is listed in the Accept headers of request
+func AcceptsMediaType(accepts []string, mediatype string) bool {
+	for _, accept := range accepts {
+		for _, value := range strings.Split(accept, ",") {
+			if strings.TrimSpace(strings.Split(value, ";")[0]) == mediatype {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// GetManifestMediaType detects the media type of a manifest from its content,
+// contentType of the request is only used when the manifest doesn't declare one
+func GetManifestMediaType(data []byte, contentType string) (string, error) {
+	var manifest struct {
+		SchemaVersion int               `json:"schemaVersion"`
+		MediaType     string            `json:"mediaType"`
+		Signatures    []json.RawMessage `json:"signatures"`
+		Manifests     json.RawMessage   `json:"manifests"`
+	}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", err
+	}
+
+	switch manifest.SchemaVersion {
+	case 1:
+		//"signatures": [] is as unsigned as no signatures
+		if len(manifest.Signatures) > 0 {
+			return SignedManifestV1MediaType, nil
+		}
+		return ManifestV1MediaType, nil
+	case 2:
+		if manifest.MediaType != "" {
+			return manifest.MediaType, nil
+		}
+
+		if contentType = strings.TrimSpace(strings.Split(contentType, ";")[0]); contentType != "" {
+			return contentType, nil
+		}
+
+		//mediaType is optional in OCI manifest and index
+		if len(manifest.Manifests) > 0 {
+			return OCIIndexMediaType, nil
+		}
+		return OCIManifestMediaType, nil
+	default:
+		return "", fmt.Errorf("Unsupported manifest schema version: %v", manifest.SchemaVersion)
+	}
+}
+
+// ParseManifest verifies and saves the manifest pushed with reference, which is a tag or a digest,
+// and returns the digest of manifest
+func ParseManifest(data []byte, namespace, repository, reference, contentType string) (string, error) {
+	mediatype, err := GetManifestMediaType(data, contentType)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := utils.DigestManifest(data)
+	if err != nil {
+		return "", err
+	}
+
+	//tampered schema1 manifests are rejected, and so are the unsigned ones since stripping the signatures would hide tampering
+	switch mediatype {
+	case ManifestV1MediaType:
+		return "", ManifestUnverifiedError{Reason: "schema1 manifest isn't signed"}
+	case SignedManifestV1MediaType:
+		if err := VerifyManifest(data); err != nil {
+			return "", err
+		}
+	}
+
+	//manifest pushed by digest is saved without tag, e.g. the children of a manifest list
+	tag := reference
+	if IsDigest(reference) {
+		if reference != digest {
+			return "", DigestInvalidError{Digest: reference}
+		}
+		tag = ""
+	}
+
+	var imageId string
+	switch mediatype {
+	case SignedManifestV1MediaType:
+		imageId, err = parseManifestV1(data, namespace, repository, tag, mediatype)
+	case ManifestV2MediaType, OCIManifestMediaType:
+		imageId, err = parseManifestV2(data, namespace, repository, tag, mediatype)
+	case ManifestListMediaType, OCIIndexMediaType:
+		err = parseManifestList(data, namespace, repository, tag, mediatype)
+	default:
+		err = fmt.Errorf("Unsupported manifest media type: %v", mediatype)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	m := new(models.Manifest)
+	if err := m.Put(namespace, repository, digest, imageId, string(data), mediatype); err != nil {
+		return "", err
+	}
+
+	//OCI manifests and indexes with a subject are indexed as its referrers, the subject needn't exist
+	if mediatype == OCIManifestMediaType || mediatype == OCIIndexMediaType {
+		subject, artifactType, annotations, err := GetManifestSubject(data)
+		if err != nil {
+			return "", err
+		}
+
+		if subject != "" {
+			if err := m.PutSubject(subject, artifactType, annotations); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return digest, nil
+}
+
+// GetManifestSubject returns the subject digest which an OCI manifest or index refers to, with its artifact type and annotations,
+// the artifact type of an image manifest without artifactType is the media type of its config
+func GetManifestSubject(data []byte) (string, string, map[string]string, error) {
+	var manifest struct {
+		ArtifactType string            `json:"artifactType"`
+		Config       *Descriptor       `json:"config"`
+		Subject      *Descriptor       `json:"subject"`
+		Annotations  map[string]string `json:"annotations"`
+	}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", "", nil, err
+	}
+
+	if manifest.Subject == nil {
+		return "", "", nil, nil
+	}
+
+	if !IsDigest(manifest.Subject.Digest) {
+		return "", "", nil, fmt.Errorf("Invalid subject digest: %v", manifest.Subject.Digest)
+	}
+
+	artifactType := manifest.ArtifactType
+	if artifactType == "" && manifest.Config != nil {
+		artifactType = manifest.Config.MediaType
+	}
+
+	return manifest.Subject.Digest, artifactType, manifest.Annotations, nil
+}
+
+// GetReferrers returns the descriptors of manifests in repository which refer to subject digest,
+// only the ones of artifactType are returned when it's not empty
+func GetReferrers(namespace, repository, subject, artifactType string) ([]Descriptor, error) {
+	m := new(models.Manifest)
+	manifests, err := m.Referrers(namespace, repository, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	referrers := []Descriptor{}
+	for _, manifest := range manifests {
+		if artifactType != "" && manifest.ArtifactType != artifactType {
+			continue
+		}
+
+		referrers = append(referrers, Descriptor{
+			MediaType:    manifest.MediaType,
+			Size:         int64(len(manifest.Manifest)),
+			Digest:       manifest.Digest,
+			ArtifactType: manifest.ArtifactType,
+			Annotations:  manifest.Annotations,
+		})
+	}
+
+	sort.Sort(descriptorsByDigest(referrers))
+
+	return referrers, nil
+}
+
+type descriptorsByDigest []Descriptor
+
+func (d descriptorsByDigest) Len() int           { return len(d) }
+func (d descriptorsByDigest) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d descriptorsByDigest) Less(i, j int) bool { return d[i].Digest < d[j].Digest }
+
+func parseManifestV1(data []byte, namespace, repository, tag, mediatype string) (string, error) {
+	var manifest ManifestV1
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", err
+	}
+
+	//every fsLayer has its history entry from top to base
+	if len(manifest.History) == 0 || len(manifest.History) != len(manifest.FSLayers) {
+		return "", fmt.Errorf("Manifest has %v history entries for %v fsLayers", len(manifest.History), len(manifest.FSLayers))
+	}
+
+	var imageId string
+	for k := len(manifest.History) - 1; k >= 0; k-- {
+		var image struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(manifest.History[k].V1Compatibility), &image); err != nil {
+			return "", fmt.Errorf("Invalid v1Compatibility of history %v: %v", k, err)
+		} else if image.ID == "" {
+			return "", fmt.Errorf("Invalid v1Compatibility of history %v: no id", k)
+		}
+
+		i := map[string]string{}
+		r := new(models.Repository)
+
+		if k == 0 && tag != "" {
+			i["Tag"] = tag
+		}
+		i["id"] = image.ID
+
+		if err := r.PutJSONFromManifests(i, namespace, repository); err != nil {
+			return "", err
+		}
+
+		if k == 0 {
+			imageId = image.ID
+
+			if tag != "" {
+				if err := r.PutTagFromManifests(imageId, namespace, repository, tag, string(data), mediatype); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+
+	return imageId, nil
+}
+
+func parseManifestV2(data []byte, namespace, repository, tag, mediatype string) (string, error) {
+	var manifest ManifestV2
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", err
+	}
+
+	//image id is taken from the config digest, which can't be a foreign layer
+	if !IsDigest(manifest.Config.Digest) {
+		return "", fmt.Errorf("Invalid config digest: %v", manifest.Config.Digest)
+	}
+
+	//config blob is pushed as a normal blob and must exist like layers
+	for _, desc := range append([]Descriptor{manifest.Config}, manifest.Layers...) {
+		if (desc.MediaType == ForeignLayerMediaType || desc.MediaType == OCIForeignLayerMediaType) && len(desc.URLs) > 0 {
+			continue
+		}
+
+		parts := strings.SplitN(desc.Digest, ":", 2)
+		if len(parts) != 2 {
+			return "", fmt.Errorf("Invalid blob digest: %v", desc.Digest)
+		}
+
+		i := new(models.Image)
+		if has, _ := i.HasTarsum(parts[1]); has == false {
+			return "", BlobUnknownError{Digest: desc.Digest}
+		}
+	}
+
+	//image id of schema2 is the digest of its config blob
+	imageId := strings.SplitN(manifest.Config.Digest, ":", 2)[1]
+
+	image := map[string]string{"id": imageId}
+	if tag != "" {
+		image["Tag"] = tag
+	}
+
+	r := new(models.Repository)
+	if err := r.PutJSONFromManifests(image, namespace, repository); err != nil {
+		return "", err
+	}
+
+	if tag != "" {
+		if err := r.PutTagFromManifests(imageId, namespace, repository, tag, string(data), mediatype); err != nil {
+			return "", err
+		}
+	}
+
+	return imageId, nil
+}
+
+func parseManifestList(data []byte, namespace, repository, tag, mediatype string) error {
+	var list ManifestList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	//every child manifest must be pushed to the same repository before the list
+	for _, desc := range list.Manifests {
+		m := new(models.Manifest)
+		if has, _, err := m.Has(namespace, repository, desc.Digest); err != nil {
+			return err
+		} else if has == false {
+			return BlobUnknownError{Digest: desc.Digest}
+		}
+	}
+
+	if tag != "" {
+		r := new(models.Repository)
+		if err := r.PutTagFromManifests("", namespace, repository, tag, string(data), mediatype); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ManifestBlobs returns the descriptors of config and layers referenced by an image manifest,
+// a manifest list or an image index references no blob but its child manifests
+func ManifestBlobs(data []byte, contentType string) ([]Descriptor, error) {
+	mediatype, err := GetManifestMediaType(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := []Descriptor{}
+	switch {
+	case mediatype == ManifestV1MediaType || mediatype == SignedManifestV1MediaType:
+		var manifest ManifestV1
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, err
+		}
+
+		for _, layer := range manifest.FSLayers {
+			blobs = append(blobs, Descriptor{MediaType: LayerMediaType, Digest: layer.BlobSum})
+		}
+	case IsManifestList(mediatype):
+	default:
+		var manifest ManifestV2
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, err
+		}
+
+		if manifest.Config.Digest != "" {
+			blobs = append(blobs, manifest.Config)
+		}
+		blobs = append(blobs, manifest.Layers...)
+	}
+
+	return blobs, nil
+}
+
+// GetManifest returns the manifest and its media type referenced by a tag or a digest in repository
+func GetManifest(namespace, repository, reference string) (bool, []byte, string, error) {
+	if !IsDigest(reference) {
+		t := new(models.Tag)
+		if err := t.Get(namespace, repository, reference); err == redis.Nil {
+			return false, nil, "", nil
+		} else if err != nil {
+			return false, nil, "", err
+		}
+
+		return true, []byte(t.Manifest), tagMediaType(t), nil
+	}
+
+	m := new(models.Manifest)
+	if has, _, err := m.Has(namespace, repository, reference); err != nil {
+		return false, nil, "", err
+	} else if has == true {
+		return true, []byte(m.Manifest), m.MediaType, nil
+	}
+
+	//manifests pushed before digest index is built are only referenced by tags, index them when found
+	r := new(models.Repository)
+	if has, _, err := r.Has(namespace, repository); err != nil || has == false {
+		return false, nil, "", err
+	}
+
+	for _, key := range r.Tags {
+		t := new(models.Tag)
+		if err := t.GetByKey(key); err != nil {
+			continue
+		}
+
+		if digest, err := utils.DigestManifest([]byte(t.Manifest)); err != nil || digest != reference {
+			continue
+		}
+
+		mediatype := tagMediaType(t)
+		if err := m.Put(namespace, repository, reference, t.ImageId, t.Manifest, mediatype); err != nil {
+			return false, nil, "", err
+		}
+
+		return true, []byte(t.Manifest), mediatype, nil
+	}
+
+	return false, nil, "", nil
+}
+
+// tagMediaType returns the media type of tag, manifests saved before media type is recorded are all schema1
+func tagMediaType(t *models.Tag) string {
+	if t.MediaType != "" {
+		return t.MediaType
+	}
+
+	if mediatype, err := GetManifestMediaType([]byte(t.Manifest), ""); err == nil {
+		return mediatype
+	}
+
+	return SignedManifestV1MediaType
+}
+
+// SelectManifest returns the digest of child manifest in a manifest list or an image index which matches the platform
+func SelectManifest(data []byte, os, architecture string) (string, error) {
+	var list ManifestList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return "", err
+	}
+
+	for _, desc := range list.Manifests {
+		if desc.Platform != nil && desc.Platform.OS == os && desc.Platform.Architecture == architecture {
+			return desc.Digest, nil
+		}
+	}
+
+	return "", fmt.Errorf("No manifest for platform %v/%v", os, architecture)
+}
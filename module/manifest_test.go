@@ -0,0 +1,111 @@
+package module
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_ParseManifestUnsignedV1(t *testing.T) {
+	manifest := []byte(`{
+   "schemaVersion": 1,
+   "name": "library/busybox",
+   "tag": "latest",
+   "architecture": "amd64",
+   "fsLayers": [{"blobSum": "sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4"}],
+   "history": [{"v1Compatibility": "{\"id\":\"d1c9\"}"}]
+}`)
+
+	_, err := ParseManifest(manifest, "library", "busybox", "latest", ManifestV1MediaType)
+	if _, ok := err.(ManifestUnverifiedError); !ok {
+		t.Errorf("Expected unsigned schema1 manifest unverified, got %v", err)
+	}
+}
+
+func Test_VerifyManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := LoadSigningKey(filepath.Join(dir, "key.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := json.MarshalIndent(ManifestV1{
+		SchemaVersion: 1,
+		Name:          "library/busybox",
+		Tag:           "latest",
+		Architecture:  "amd64",
+		FSLayers:      []FSLayer{{BlobSum: EmptyLayerDigest}},
+		History:       []HistoryV1{{V1Compatibility: `{"id":"d1c9"}`}},
+	}, "", "   ")
+
+	signed, err := SignManifest(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyManifest(signed); err != nil {
+		t.Fatalf("Expected manifest signed by registry verified, got %v", err)
+	}
+
+	end := strings.LastIndex(string(signed), "}")
+	cases := []struct {
+		name     string
+		manifest string
+	}{
+		{"tampered payload", strings.Replace(string(signed), `"d1c9`, `"e2d0`, 1)},
+		{"field after payload", string(signed[:end]) + `,"history":[{"v1Compatibility":"{\"id\":\"e2d0\"}"}]` + string(signed[end:])},
+		{"no signatures", string(payload[:len(payload)-2]) + `,"signatures":[]}`},
+	}
+
+	for _, c := range cases {
+		if err := VerifyManifest([]byte(c.manifest)); err == nil {
+			t.Errorf("%v: expected manifest unverified", c.name)
+		} else if _, ok := err.(ManifestUnverifiedError); !ok {
+			t.Errorf("%v: expected manifest unverified, got %v", c.name, err)
+		}
+
+		if _, err := ParseManifest([]byte(c.manifest), "library", "busybox", "latest", SignedManifestV1MediaType); err == nil {
+			t.Errorf("%v: expected manifest rejected", c.name)
+		}
+	}
+
+	if mediatype, err := GetManifestMediaType([]byte(cases[2].manifest), ""); err != nil || mediatype != ManifestV1MediaType {
+		t.Errorf("Expected manifest with empty signatures unsigned, got %v %v", mediatype, err)
+	}
+}
+
+func Test_ParseManifestInvalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		manifest string
+	}{
+		{"no history", `{"schemaVersion":1,"name":"library/busybox","tag":"latest","signatures":[]}`},
+		{"history without fsLayers", `{"schemaVersion":1,"history":[{"v1Compatibility":"{\"id\":\"d1c9\"}"}]}`},
+		{"history of wrong type", `{"schemaVersion":1,"fsLayers":[{"blobSum":"sha256:a3ed"}],"history":{"v1Compatibility":"{}"}}`},
+		{"v1Compatibility of wrong type", `{"schemaVersion":1,"fsLayers":[{"blobSum":"sha256:a3ed"}],"history":[{"v1Compatibility":{"id":"d1c9"}}]}`},
+		{"v1Compatibility without id", `{"schemaVersion":1,"fsLayers":[{"blobSum":"sha256:a3ed"}],"history":[{"v1Compatibility":"{\"parent\":\"d1c9\"}"}]}`},
+		{"v1Compatibility with id of wrong type", `{"schemaVersion":1,"fsLayers":[{"blobSum":"sha256:a3ed"}],"history":[{"v1Compatibility":"{\"id\":1}"}]}`},
+	}
+
+	for _, c := range cases {
+		if _, err := parseManifestV1([]byte(c.manifest), "library", "busybox", "latest", SignedManifestV1MediaType); err == nil {
+			t.Errorf("%v: expected manifest invalid", c.name)
+		}
+	}
+
+	//foreign layers with urls aren't looked up in the blob store, a config pretending to be one must still be a digest
+	for _, digest := range []string{"a3ed", "", "sha256:"} {
+		manifest := `{"schemaVersion":2,"mediaType":"` + ManifestV2MediaType + `","config":{"mediaType":"` + ForeignLayerMediaType + `","digest":"` + digest + `","urls":["http://example.com/config"]},"layers":[]}`
+
+		if _, err := ParseManifest([]byte(manifest), "library", "busybox", "latest", ""); err == nil {
+			t.Errorf("Expected manifest with config digest %q invalid", digest)
+		}
+	}
+}
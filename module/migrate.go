@@ -0,0 +1,105 @@
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/astaxie/beego/logs"
+
+	"github.com/containerops/dockyard/models"
+)
+
+// MigrateBlobs moves the layers kept in tarsum and V1 image directories into blob store by their content.
+// Layers already in blob store are skipped, so it's safe to run again after an interruption.
+func MigrateBlobs(log *logs.BeeLogger) error {
+	i := new(models.Image)
+
+	tarsums, err := i.ListTarsums()
+	if err != nil {
+		return err
+	}
+
+	for _, tarsum := range tarsums {
+		t := new(models.Image)
+		if has, _ := t.HasTarsum(tarsum); has == false || t.Path == "" || IsBlobPath(t.Path) {
+			continue
+		}
+
+		old := t.Path
+		digest, path, size, err := migrateBlob(old)
+		if err != nil {
+			log.Error("[MIGRATE] Move blob %v failed: %v", tarsum, err.Error())
+			continue
+		}
+
+		if digest != "sha256:"+tarsum {
+			log.Warn("[MIGRATE] Content of blob %v is digested as %v", tarsum, digest)
+		}
+
+		t.Path, t.Size = path, size
+		if err := t.PutTarsum(tarsum); err != nil {
+			log.Error("[MIGRATE] Save blob %v failed: %v", tarsum, err.Error())
+			continue
+		}
+
+		os.RemoveAll(filepath.Dir(old))
+		log.Info("[MIGRATE] Moved blob %v to %v", old, path)
+	}
+
+	images, err := i.List()
+	if err != nil {
+		return err
+	}
+
+	for _, imageId := range images {
+		image := new(models.Image)
+		if has, _, _ := image.Has(imageId); has == false || image.Path == "" || IsBlobPath(image.Path) {
+			continue
+		}
+
+		old := image.Path
+		_, path, size, err := migrateBlob(old)
+		if err != nil {
+			log.Error("[MIGRATE] Move layer of image %v failed: %v", imageId, err.Error())
+			continue
+		}
+
+		image.Path, image.Size = path, size
+		if err := image.Save(); err != nil {
+			log.Error("[MIGRATE] Save image %v failed: %v", imageId, err.Error())
+			continue
+		}
+
+		os.RemoveAll(filepath.Dir(old))
+		log.Info("[MIGRATE] Moved layer %v to %v", old, path)
+	}
+
+	return nil
+}
+
+// migrateBlob digests a layer file with sha256 and moves it into blob store
+func migrateBlob(layerfile string) (string, string, int64, error) {
+	fd, err := os.Open(layerfile)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, fd)
+	fd.Close()
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	digest := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+
+	path, size, err := CommitBlob(layerfile, digest)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	return digest, path, size, nil
+}
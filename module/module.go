@@ -1,90 +1,97 @@
 package module
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
 
-	"github.com/containerops/dockyard/models"
+	"github.com/containerops/wrench/setting"
 	"github.com/containerops/wrench/utils"
 )
 
-func ParseManifest(data []byte) error {
-
-	var manifest map[string]interface{}
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		return err
+// ParseContentRange parses the Content-Range header of a chunked blob upload, like "0-1023" or "bytes 0-1023"
+func ParseContentRange(value string) (int64, int64, error) {
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "bytes"))
+	if i := strings.Index(value, "/"); i >= 0 {
+		value = value[:i]
 	}
 
-	tag := manifest["tag"]
-	namespace, repository := strings.Split(manifest["name"].(string), "/")[0], strings.Split(manifest["name"].(string), "/")[1]
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid content range: %v", value)
+	}
 
-	for k := len(manifest["history"].([]interface{})) - 1; k >= 0; k-- {
-		v := manifest["history"].([]interface{})[k]
-		compatibility := v.(map[string]interface{})["v1Compatibility"].(string)
+	start, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid content range: %v", value)
+	}
 
-		var image map[string]interface{}
-		if err := json.Unmarshal([]byte(compatibility), &image); err != nil {
-			return err
-		}
+	end, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("Invalid content range: %v", value)
+	}
 
-		i := map[string]string{}
-		r := new(models.Repository)
+	return start, end, nil
+}
 
-		if k == 0 {
-			i["Tag"] = tag.(string)
-		}
-		i["id"] = image["id"].(string)
+// AppendImgLayer writes a chunk of layer data into the upload file at the given offset and returns the bytes written
+func AppendImgLayer(layerfile string, offset int64, reader io.Reader) (int64, error) {
+	fd, err := os.OpenFile(layerfile, os.O_WRONLY|os.O_CREATE, 0777)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
 
-		if err := r.PutJSONFromManifests(i, namespace, repository); err != nil {
-			return err
-		}
+	if err := fd.Truncate(offset); err != nil {
+		return 0, err
+	}
 
-		if k == 0 {
-			if err := r.PutTagFromManifests(image["id"].(string), namespace, repository, tag.(string), string(data)); err != nil {
-				return err
-			}
-		}
+	if _, err := fd.Seek(offset, os.SEEK_SET); err != nil {
+		return 0, err
 	}
 
-	return nil
+	return io.Copy(fd, reader)
 }
 
-func CopyImgLayer(srcPath, srcFile, dstPath, dstFile string, reqbody []byte) (int, error) {
-	if !utils.IsDirExist(dstPath) {
-		os.MkdirAll(dstPath, os.ModePerm)
+// VerifyImgLayer hashes the layer file with the algorithm of digest and checks the result matches digest
+func VerifyImgLayer(layerfile, digest string) error {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return fmt.Errorf("Invalid digest: %v", digest)
 	}
 
-	if utils.IsFileExist(dstFile) {
-		os.Remove(dstFile)
+	algorithm := utils.Algorithm(parts[0])
+	if !algorithm.Available() {
+		return fmt.Errorf("Unsupported digest algorithm: %v", parts[0])
 	}
 
-	var data []byte
-	if _, err := os.Stat(srcFile); err == nil {
-		data, _ = ioutil.ReadFile(srcFile)
-		if err := ioutil.WriteFile(dstFile, data, 0777); err != nil {
-			return 0, err
-		}
-		os.RemoveAll(srcPath)
-	} else {
-		data = reqbody
-		if err := ioutil.WriteFile(dstFile, data, 0777); err != nil {
-			return 0, err
-		}
+	fd, err := os.Open(layerfile)
+	if err != nil {
+		return err
 	}
+	defer fd.Close()
 
-	return len(data), nil
+	digester := algorithm.New()
+	if _, err := io.Copy(digester.Hash(), fd); err != nil {
+		return err
+	}
+
+	if computed := digester.Digest(); computed != strings.ToLower(digest) {
+		return fmt.Errorf("Digest mismatch, provided %v but computed %v", digest, computed)
+	}
+
+	return nil
 }
 
-//all as below are ported to support for docker to parse request URL,and it would be update soon
+// all as below are ported to support for docker to parse request URL,and it would be update soon
 func parseIP(ipStr string) net.IP {
 	ip := net.ParseIP(ipStr)
 	if ip == nil {
@@ -134,6 +141,16 @@ type RouteDescriptor struct {
 
 var RepositoryNameComponentRegexp = regexp.MustCompile(`[a-z0-9]+(?:[._-][a-z0-9]+)*`)
 var RepositoryNameRegexp = regexp.MustCompile(`(?:` + RepositoryNameComponentRegexp.String() + `/)*` + RepositoryNameComponentRegexp.String())
+
+// SplitRepositoryName splits a full repository name at its last component, names of single component get the default namespace
+func SplitRepositoryName(name string) (string, string) {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+
+	return setting.DefaultNamespace, name
+}
+
 var TagNameRegexp = regexp.MustCompile(`[\w][\w.-]{0,127}`)
 var DigestRegexp = regexp.MustCompile(`[a-zA-Z0-9-_+.]+:[a-fA-F0-9]+`)
 
@@ -0,0 +1,72 @@
+package module
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/wrench/setting"
+)
+
+// usernames and organization names share the namespaces of repositories, one name belongs to either of them
+var AccountNameRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+
+const (
+	NamespaceUser         = "user"
+	NamespaceOrganization = "organization"
+)
+
+// ValidAccountName reports whether name could be a username, an organization or a team
+func ValidAccountName(name string) bool {
+	return len(name) >= 2 && len(name) <= 64 && AccountNameRegexp.MatchString(name)
+}
+
+// NamespaceOwner returns the user or organization owning namespace by its first component,
+// e.g. team/project is owned by team. Kind is empty when nobody owns it.
+func NamespaceOwner(namespace string) (kind, name string, err error) {
+	name = strings.SplitN(namespace, "/", 2)[0]
+
+	u := new(models.User)
+	if has, _, err := u.Has(name); err != nil {
+		return "", "", err
+	} else if has == true {
+		return NamespaceUser, name, nil
+	}
+
+	o := new(models.Organization)
+	if has, _, err := o.Has(name); err != nil {
+		return "", "", err
+	} else if has == true {
+		return NamespaceOrganization, name, nil
+	}
+
+	return "", name, nil
+}
+
+// IsAdministrator reports whether user is an administrator of Dockyard
+func IsAdministrator(username string) bool {
+	for _, name := range setting.Administrators {
+		if username != "" && name == username {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NamespaceHasRepositories reports whether repositories were pushed to the namespaces under name,
+// which are only claimed by administrators so nobody takes over the repositories pushed before, e.g. library.
+func NamespaceHasRepositories(name string) (bool, error) {
+	names, err := new(models.Repository).List()
+	if err != nil {
+		return false, err
+	}
+
+	for _, repository := range names {
+		if strings.HasPrefix(repository, name+"/") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,34 @@
+package module
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// Paginate sorts entries lexically and returns at most n of them after last,
+// with whether more entries are left. A negative n returns all entries after last.
+func Paginate(entries []string, last string, n int) ([]string, bool) {
+	sort.Strings(entries)
+
+	start := sort.SearchStrings(entries, last)
+	if last != "" && start < len(entries) && entries[start] == last {
+		start++
+	}
+	entries = entries[start:]
+
+	if n < 0 || n >= len(entries) {
+		return entries, false
+	}
+
+	return entries[:n], true
+}
+
+// PaginationLink returns the Link header pointing to the next page of path
+func PaginationLink(path, last string, n int) string {
+	values := url.Values{}
+	values.Set("last", last)
+	values.Set("n", fmt.Sprint(n))
+
+	return fmt.Sprintf("<%s?%s>; rel=\"next\"", path, values.Encode())
+}
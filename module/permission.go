@@ -0,0 +1,132 @@
+package module
+
+import (
+	"strings"
+
+	"github.com/containerops/dockyard/models"
+)
+
+var permissionRanks = map[string]int{
+	models.PermissionRead:  1,
+	models.PermissionWrite: 2,
+	models.PermissionAdmin: 3,
+}
+
+// ValidPermission reports whether level is one of read, write and admin
+func ValidPermission(level string) bool {
+	return permissionRanks[level] > 0
+}
+
+// PermissionAllows reports whether the permission level includes required
+func PermissionAllows(level, required string) bool {
+	return permissionRanks[level] >= permissionRanks[required]
+}
+
+// PermissionActions returns the actions on repositories allowed by the permission level
+func PermissionActions(level string) []string {
+	actions := []string{}
+	for _, action := range []string{"pull", "push", "delete"} {
+		if PermissionAllows(level, actionPermissions[action]) {
+			actions = append(actions, action)
+		}
+	}
+
+	return actions
+}
+
+func higherPermission(a, b string) string {
+	if permissionRanks[b] > permissionRanks[a] {
+		return b
+	}
+
+	return a
+}
+
+// RepositoryPermission returns the permission level of user on repository, an empty user is anonymous and an empty level is no access.
+// Public repositories are read by anyone, and so are the repositories not cached yet in proxy mode. Owners of the namespace administer it,
+// other members of the owning organization read it, and namespaces owned by nobody are administered by administrators and the user named
+// after the namespace. Levels granted to the user and its teams are added. Robots have their level on the namespaces under the one they
+// belong to and nothing more.
+func RepositoryPermission(username, namespace, repository string) (string, error) {
+	level := ""
+
+	r := new(models.Repository)
+	if has, _, err := r.Has(namespace, repository); err != nil {
+		return "", err
+	} else if (has == true && r.Privated == false) || (has == false && ProxyEnabled()) {
+		level = models.PermissionRead
+	}
+
+	if username == "" {
+		return level, nil
+	}
+
+	if robotNamespace, name, ok := models.SplitRobotName(username); ok {
+		robot := new(models.Robot)
+		if has, _, err := robot.Has(robotNamespace, name); err != nil {
+			return "", err
+		} else if has == true && robotNamespace == strings.SplitN(namespace, "/", 2)[0] {
+			level = higherPermission(level, robot.Level)
+		}
+
+		return level, nil
+	}
+
+	kind, owner, err := NamespaceOwner(namespace)
+	if err != nil {
+		return "", err
+	}
+
+	switch kind {
+	case NamespaceUser:
+		if owner == username {
+			return models.PermissionAdmin, nil
+		}
+	case NamespaceOrganization:
+		o := new(models.Organization)
+		if _, _, err := o.Has(owner); err != nil {
+			return "", err
+		}
+
+		if isOwner, err := o.IsOwner(username); err != nil {
+			return "", err
+		} else if isOwner == true {
+			return models.PermissionAdmin, nil
+		}
+
+		if isMember, err := o.IsMember(username); err != nil {
+			return "", err
+		} else if isMember == true {
+			level = higherPermission(level, models.PermissionRead)
+		}
+	default:
+		//users authenticated by htpasswd or ldap aren't signed up, they still own the namespaces of their names
+		if IsAdministrator(username) || owner == username {
+			return models.PermissionAdmin, nil
+		}
+	}
+
+	p := new(models.Permission)
+	if _, _, err := p.Has(namespace, repository); err != nil {
+		return "", err
+	}
+
+	level = higherPermission(level, p.Users[username])
+
+	for name, granted := range p.Teams {
+		parts := strings.SplitN(name, "/", 2)
+		//teams granting no more than the level found aren't looked up
+		if len(parts) != 2 || permissionRanks[granted] <= permissionRanks[level] {
+			continue
+		}
+
+		t := new(models.Team)
+		if has, _, err := t.Has(parts[0], parts[1]); err != nil {
+			return "", err
+		} else if has == true && t.HasMember(username) {
+			level = granted
+		}
+	}
+
+	return level, nil
+}
@@ -0,0 +1,240 @@
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/wrench/setting"
+	"github.com/containerops/wrench/utils"
+)
+
+// In proxy mode manifests and blobs missing locally are fetched from the upstream registry and cached,
+// tags fetched from upstream are refreshed once they're older than manifest ttl.
+
+// manifest media types accepted from upstream
+var proxyAccepts = []string{
+	OCIIndexMediaType,
+	OCIManifestMediaType,
+	ManifestListMediaType,
+	ManifestV2MediaType,
+	SignedManifestV1MediaType,
+	ManifestV1MediaType,
+}
+
+var (
+	upstream     *Remote
+	upstreamOnce sync.Once
+)
+
+// ProxyEnabled reports whether Dockyard runs as a pull through cache of upstream registry
+func ProxyEnabled() bool {
+	return setting.ProxyRemoteURL != ""
+}
+
+// proxyRemote returns the upstream registry, tokens it issues are kept across requests
+func proxyRemote() *Remote {
+	upstreamOnce.Do(func() {
+		upstream = NewRemote(setting.ProxyRemoteURL, setting.ProxyUsername, setting.ProxyPassword)
+	})
+
+	return upstream
+}
+
+// ProxyStale reports whether the manifest of reference should be fetched from upstream,
+// which is when it's missing locally, or it's a tag fetched from upstream earlier than manifest ttl
+func ProxyStale(namespace, repository, reference string) bool {
+	if IsDigest(reference) {
+		m := new(models.Manifest)
+		has, _, err := m.Has(namespace, repository, reference)
+		return err == nil && has == false
+	}
+
+	t := new(models.Tag)
+	if err := t.Get(namespace, repository, reference); err != nil {
+		return true
+	}
+
+	return t.Fetched > 0 && time.Since(time.Unix(0, t.Fetched*int64(time.Millisecond))) > setting.ProxyManifestTTL
+}
+
+// ProxyManifest fetches the manifest of reference from upstream and saves it in repository,
+// manifests are saved without checking their blobs which are fetched when they're pulled
+func ProxyManifest(namespace, repository, reference string) error {
+	header := http.Header{}
+	header.Set("Accept", strings.Join(proxyAccepts, ", "))
+
+	resp, err := proxyRemote().Do(namespace+"/"+repository, "GET", fmt.Sprintf("/v2/%s/%s/manifests/%s", namespace, repository, reference), header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Upstream responds %v", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	mediatype, err := GetManifestMediaType(data, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+
+	digest, err := utils.DigestManifest(data)
+	if err != nil {
+		return err
+	} else if IsDigest(reference) && digest != reference {
+		return DigestInvalidError{Digest: reference}
+	}
+
+	//image id of schema2 is the digest of its config blob, the others are only listed by digest
+	imageId := ""
+	if mediatype == ManifestV2MediaType || mediatype == OCIManifestMediaType {
+		var manifest ManifestV2
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return err
+		}
+
+		if parts := strings.SplitN(manifest.Config.Digest, ":", 2); len(parts) == 2 {
+			imageId = parts[1]
+		}
+	}
+
+	r := new(models.Repository)
+	if has, _, err := r.Has(namespace, repository); err != nil {
+		return err
+	} else if has == false {
+		if err := r.Put(namespace, repository, "", "proxy", setting.APIVERSION_V2); err != nil {
+			return err
+		}
+	}
+
+	m := new(models.Manifest)
+	if err := m.Put(namespace, repository, digest, imageId, string(data), mediatype); err != nil {
+		return err
+	}
+
+	if IsDigest(reference) {
+		return nil
+	}
+
+	if err := r.PutTagFromManifests(imageId, namespace, repository, reference, string(data), mediatype); err != nil {
+		return err
+	}
+
+	t := new(models.Tag)
+	if err := t.Get(namespace, repository, reference); err != nil {
+		return err
+	}
+
+	t.Fetched = time.Now().UnixNano() / int64(time.Millisecond)
+	return t.Save()
+}
+
+// ProxyStatBlob returns the size of blob in upstream
+func ProxyStatBlob(namespace, repository, digest string) (int64, error) {
+	resp, err := proxyRemote().Do(namespace+"/"+repository, "HEAD", fmt.Sprintf("/v2/%s/%s/blobs/%s", namespace, repository, digest), http.Header{}, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, BlobUnknownError{Digest: digest}
+	} else if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("Upstream responds %v", resp.Status)
+	}
+
+	return resp.ContentLength, nil
+}
+
+// ProxyBlob streams the blob from upstream to w and saves it in blob store at the same time,
+// it's kept fetching when client goes away and only linked to repository when the content matches digest.
+func ProxyBlob(namespace, repository, digest string, w http.ResponseWriter) error {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return DigestInvalidError{Digest: digest}
+	}
+
+	resp, err := proxyRemote().Do(namespace+"/"+repository, "GET", fmt.Sprintf("/v2/%s/%s/blobs/%s", namespace, repository, digest), http.Header{}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return BlobUnknownError{Digest: digest}
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Upstream responds %v", resp.Status)
+	}
+
+	tmp := filepath.Join(setting.ImagePath, "blobs", "tmp")
+	if err := os.MkdirAll(tmp, os.ModePerm); err != nil {
+		return err
+	}
+
+	fd, err := ioutil.TempFile(tmp, "proxy")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fd.Name())
+
+	w.Header().Set("Content-Type", "application/x-gzip")
+	w.Header().Set("Docker-Content-Digest", digest)
+	if resp.ContentLength >= 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(resp.ContentLength))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(fd, hash, &clientWriter{w: w}), resp.Body)
+	fd.Close()
+	if err != nil {
+		return err
+	}
+
+	if actual := "sha256:" + hex.EncodeToString(hash.Sum(nil)); actual != digest {
+		return fmt.Errorf("Upstream blob %v is digested as %v", digest, actual)
+	}
+
+	path, size, err := CommitBlob(fd.Name(), digest)
+	if err != nil {
+		return err
+	}
+
+	i := new(models.Image)
+	i.Path, i.Size = path, size
+	if err := i.PutTarsum(strings.TrimPrefix(digest, "sha256:")); err != nil {
+		return err
+	}
+
+	r := new(models.Repository)
+	return r.PutBlob(namespace, repository, digest)
+}
+
+// clientWriter drops the error of writing to client, so the content is still saved after client goes away
+type clientWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (c *clientWriter) Write(p []byte) (int, error) {
+	if c.err == nil {
+		_, c.err = c.w.Write(p)
+	}
+
+	return len(p), nil
+}
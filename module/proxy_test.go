@@ -0,0 +1,205 @@
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/wrench/db"
+	"github.com/containerops/wrench/setting"
+)
+
+// initDB connects the redis of DOCKYARD_TEST_REDIS or localhost:6379 with db 15,
+// tests caching manifests and blobs are skipped without redis.
+func initDB(t *testing.T) {
+	addr := os.Getenv("DOCKYARD_TEST_REDIS")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	if err := db.InitDB(addr, "", 15); err != nil {
+		t.Skipf("Redis isn't available: %v", err)
+	}
+}
+
+// newUpstream starts the upstream registry of proxy serving the manifests and blobs by path under /v2/,
+// e.g. library/busybox/manifests/latest
+func newUpstream(contents map[string][]byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, has := contents[strings.TrimPrefix(r.URL.Path, "/v2/")]
+		if !has {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if strings.Contains(r.URL.Path, "/manifests/") {
+			w.Header().Set("Content-Type", ManifestV2MediaType)
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Write(data)
+	}))
+
+	setting.ProxyRemoteURL, setting.ProxyUsername, setting.ProxyPassword = server.URL, "", ""
+	upstreamOnce = sync.Once{}
+
+	return server
+}
+
+func sha256Digest(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+func Test_ProxyManifest(t *testing.T) {
+	initDB(t)
+
+	setting.ProxyManifestTTL = time.Hour
+
+	config := sha256Digest([]byte("config"))
+	manifest, _ := json.Marshal(ManifestV2{
+		SchemaVersion: 2,
+		MediaType:     ManifestV2MediaType,
+		Config:        Descriptor{MediaType: ImageConfigMediaType, Digest: config},
+		Layers:        []Descriptor{{MediaType: LayerMediaType, Digest: sha256Digest([]byte("layer"))}},
+	})
+	digest := sha256Digest(manifest)
+
+	namespace, repository := "proxy", fmt.Sprintf("manifest%d", time.Now().UnixNano())
+	name := namespace + "/" + repository
+
+	server := newUpstream(map[string][]byte{
+		name + "/manifests/latest":    manifest,
+		name + "/manifests/" + digest: manifest,
+		name + "/manifests/" + config: manifest,
+	})
+	defer server.Close()
+
+	if !ProxyStale(namespace, repository, "latest") || !ProxyStale(namespace, repository, digest) {
+		t.Fatalf("Expected manifests missing locally stale")
+	}
+
+	if err := ProxyManifest(namespace, repository, "latest"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ProxyStale(namespace, repository, "latest") || ProxyStale(namespace, repository, digest) {
+		t.Errorf("Expected manifest fetched just now fresh")
+	}
+
+	if has, data, mediatype, err := GetManifest(namespace, repository, "latest"); err != nil || has == false || string(data) != string(manifest) || mediatype != ManifestV2MediaType {
+		t.Errorf("Expected manifest of upstream saved with tag, got %v %q %v %v", has, data, mediatype, err)
+	}
+
+	tag := new(models.Tag)
+	if err := tag.Get(namespace, repository, "latest"); err != nil {
+		t.Fatal(err)
+	}
+
+	tag.Fetched = time.Now().Add(-2*time.Hour).UnixNano() / int64(time.Millisecond)
+	if err := tag.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ProxyStale(namespace, repository, "latest") {
+		t.Errorf("Expected tag fetched earlier than manifest ttl stale")
+	}
+
+	tag.Fetched = 0
+	if err := tag.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ProxyStale(namespace, repository, "latest") {
+		t.Errorf("Expected pushed tag never stale")
+	}
+
+	//upstream serves a manifest which isn't the one of digest requested
+	if err := ProxyManifest(namespace, repository, config); err == nil {
+		t.Errorf("Expected manifest not matching its digest refused")
+	} else if _, ok := err.(DigestInvalidError); !ok {
+		t.Errorf("Expected digest invalid, got %v", err)
+	}
+
+	if !ProxyStale(namespace, repository, config) {
+		t.Errorf("Expected manifest refused not saved")
+	}
+}
+
+func Test_ProxyBlob(t *testing.T) {
+	initDB(t)
+
+	dir, err := ioutil.TempDir("", "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setting.ImagePath = dir
+
+	layer := []byte(fmt.Sprintf("layer %d", time.Now().UnixNano()))
+	digest := sha256Digest(layer)
+	forged := sha256Digest([]byte("forged"))
+
+	namespace, repository := "proxy", fmt.Sprintf("blob%d", time.Now().UnixNano())
+	name := namespace + "/" + repository
+
+	server := newUpstream(map[string][]byte{
+		name + "/blobs/" + digest: layer,
+		name + "/blobs/" + forged: layer,
+	})
+	defer server.Close()
+
+	if size, err := ProxyStatBlob(namespace, repository, digest); err != nil || size != int64(len(layer)) {
+		t.Errorf("Expected size %v of upstream blob, got %v %v", len(layer), size, err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := ProxyBlob(namespace, repository, digest, w); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusOK || w.Body.String() != string(layer) || w.Header().Get("Docker-Content-Digest") != digest {
+		t.Errorf("Expected blob streamed to client, got %v %q %v", w.Code, w.Body.String(), w.Header().Get("Docker-Content-Digest"))
+	}
+
+	path, _ := BlobPath(digest)
+	if data, err := ioutil.ReadFile(path); err != nil || string(data) != string(layer) {
+		t.Errorf("Expected blob committed to blob store, got %q %v", data, err)
+	}
+
+	r := new(models.Repository)
+	if has, err := r.HasBlob(namespace, repository, digest); err != nil || has == false {
+		t.Errorf("Expected blob linked to repository, got %v %v", has, err)
+	}
+
+	//upstream serves content which isn't the one of digest requested
+	if err := ProxyBlob(namespace, repository, forged, httptest.NewRecorder()); err == nil {
+		t.Errorf("Expected blob not matching its digest refused")
+	}
+
+	if path, _ := BlobPath(forged); fileExists(path) {
+		t.Errorf("Expected blob refused not committed")
+	}
+
+	if has, err := r.HasBlob(namespace, repository, forged); err != nil || has == true {
+		t.Errorf("Expected blob refused not linked, got %v %v", has, err)
+	}
+
+	if _, err := ProxyStatBlob(namespace, repository, sha256Digest([]byte("missing"))); err == nil {
+		t.Errorf("Expected blob missing upstream unknown")
+	} else if _, ok := err.(BlobUnknownError); !ok {
+		t.Errorf("Expected blob unknown, got %v", err)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
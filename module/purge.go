@@ -0,0 +1,92 @@
+package module
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+
+	"github.com/containerops/dockyard/models"
+)
+
+// upload session directories are named by the md5 of a uuid, see PostBlobsV2Handler
+var uploadDirRegexp = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+// PurgeUploads removes upload sessions and their temporary directories under path which aren't updated within age.
+// Directories without any upload state in database are treated as abandoned by an older version and purged by modify time.
+func PurgeUploads(path string, age time.Duration, log *logs.BeeLogger) error {
+	deadline := time.Now().Add(-age)
+	known := map[string]bool{}
+
+	u := new(models.Upload)
+	uploads, err := u.List()
+	if err != nil {
+		return err
+	}
+
+	for _, upload := range uploads {
+		known[upload.UUID] = true
+
+		//uploads still receiving chunks are kept however long ago they started
+		if time.Unix(0, upload.Updated*int64(time.Millisecond)).After(deadline) {
+			continue
+		}
+
+		dir := fmt.Sprintf("%v/%v", path, upload.UUID)
+		if err := os.RemoveAll(dir); err != nil {
+			log.Error("[REGISTRY API V2] Purge upload %v failed: %v", upload.UUID, err.Error())
+			continue
+		}
+
+		if err := u.Delete(upload.UUID); err != nil {
+			log.Error("[REGISTRY API V2] Delete upload state %v failed: %v", upload.UUID, err.Error())
+			continue
+		}
+
+		log.Info("[REGISTRY API V2] Purged upload %v of %v/%v, %v bytes reclaimed", upload.UUID, upload.Namespace, upload.Repository, upload.Offset)
+	}
+
+	dirs, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() || !uploadDirRegexp.MatchString(dir.Name()) || known[dir.Name()] {
+			continue
+		}
+
+		if dir.ModTime().After(deadline) {
+			continue
+		}
+
+		if err := os.RemoveAll(fmt.Sprintf("%v/%v", path, dir.Name())); err != nil {
+			log.Error("[REGISTRY API V2] Purge upload path %v failed: %v", dir.Name(), err.Error())
+			continue
+		}
+
+		log.Info("[REGISTRY API V2] Purged orphan upload path %v", dir.Name())
+	}
+
+	return nil
+}
+
+// StartUploadPurger runs PurgeUploads every interval in background, a zero age or interval disables it.
+func StartUploadPurger(path string, age, interval time.Duration, log *logs.BeeLogger) {
+	if age <= 0 || interval <= 0 {
+		return
+	}
+
+	go func() {
+		for {
+			if err := PurgeUploads(path, age, log); err != nil {
+				log.Error("[REGISTRY API V2] Purge uploads failed: %v", err.Error())
+			}
+
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,163 @@
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var challengeRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Remote is a V2 registry accessed with the credentials, either by basic auth
+// or by the bearer token requested from the realm it challenges, which is cached by repository
+type Remote struct {
+	URL      string
+	Username string
+	Password string
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func NewRemote(url, username, password string) *Remote {
+	return &Remote{URL: strings.TrimSuffix(url, "/"), Username: username, Password: password, tokens: map[string]string{}}
+}
+
+// Do sends request of repository name to remote, path could be an absolute url like the Location of upload.
+// The body is sent again after a token is requested, so it must be seekable.
+func (r *Remote) Do(name, method, path string, header http.Header, body io.ReadSeeker) (*http.Response, error) {
+	r.mu.Lock()
+	token := r.tokens[name]
+	r.mu.Unlock()
+
+	resp, err := r.do(method, path, header, body, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	//the cached token has expired, lacks the scope or there is none
+	if token, err = r.token(challenge); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.tokens[name] = token
+	r.mu.Unlock()
+
+	if body != nil {
+		if _, err := body.Seek(0, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.do(method, path, header, body, token)
+}
+
+func (r *Remote) do(method, path string, header http.Header, body io.ReadSeeker, token string) (*http.Response, error) {
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		path = r.URL + path
+	}
+
+	var reader io.Reader
+	var length int64
+	if body != nil {
+		//blobs are sent with their length rather than chunked
+		offset, err := body.Seek(0, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := body.Seek(0, 2)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := body.Seek(offset, 0); err != nil {
+			return nil, err
+		}
+
+		reader, length = body, end-offset
+	}
+
+	req, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = length
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+
+	return http.DefaultClient.Do(req)
+}
+
+// token requests a bearer token from the realm of challenge
+func (r *Remote) token(challenge string) (string, error) {
+	params := map[string]string{}
+	for _, match := range challengeRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+
+	if params["realm"] == "" {
+		return "", fmt.Errorf("Invalid challenge of %v: %v", r.URL, challenge)
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+
+	req, err := http.NewRequest("GET", params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Token server of %v responds %v", r.URL, resp.Status)
+	}
+
+	var result struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if result.Token == "" {
+		result.Token = result.AccessToken
+	}
+
+	return result.Token, nil
+}
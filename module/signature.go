@@ -0,0 +1,107 @@
+package module
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/libtrust"
+)
+
+// key of registry to sign the schema1 manifests synthesized or rewritten by Dockyard
+var signingKey libtrust.PrivateKey
+
+// ManifestUnverifiedError is returned when the JWS signatures of a schema1 manifest don't match its content
+type ManifestUnverifiedError struct {
+	Reason string
+}
+
+func (e ManifestUnverifiedError) Error() string {
+	return fmt.Sprintf("Manifest failed signature verification: %v", e.Reason)
+}
+
+// LoadSigningKey loads the signing key of registry from keyfile, a new EC P-256 key is generated and saved when it doesn't exist
+func LoadSigningKey(keyfile string) error {
+	key, err := libtrust.LoadKeyFile(keyfile)
+	if err == libtrust.ErrKeyFileDoesNotExist {
+		if key, err = libtrust.GenerateECP256PrivateKey(); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(keyfile), 0700); err != nil {
+			return err
+		}
+
+		if err := libtrust.SaveKey(keyfile, key); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	signingKey = key
+
+	return nil
+}
+
+// VerifyManifest checks every JWS signature of a signed schema1 manifest against its payload,
+// the manifest must be signed at least once and have nothing besides the signatures outside of its payload
+func VerifyManifest(data []byte) error {
+	jsig, err := libtrust.ParsePrettySignature(data, "signatures")
+	if err != nil {
+		return ManifestUnverifiedError{Reason: err.Error()}
+	}
+
+	keys, err := jsig.Verify()
+	if err != nil {
+		return ManifestUnverifiedError{Reason: err.Error()}
+	} else if len(keys) == 0 {
+		return ManifestUnverifiedError{Reason: "manifest has no signatures"}
+	}
+
+	payload, err := jsig.Payload()
+	if err != nil {
+		return ManifestUnverifiedError{Reason: err.Error()}
+	}
+
+	//fields added or repeated after the payload aren't signed but would be parsed
+	var signed, content map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &signed); err != nil {
+		return ManifestUnverifiedError{Reason: err.Error()}
+	}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return ManifestUnverifiedError{Reason: err.Error()}
+	}
+	delete(content, "signatures")
+
+	if len(content) != len(signed) {
+		return ManifestUnverifiedError{Reason: "manifest has fields which aren't signed"}
+	}
+	for k, v := range content {
+		if !bytes.Equal(signed[k], v) {
+			return ManifestUnverifiedError{Reason: fmt.Sprintf("field %v isn't signed", k)}
+		}
+	}
+
+	return nil
+}
+
+// SignManifest signs the payload of a schema1 manifest with the signing key of registry
+func SignManifest(payload []byte) ([]byte, error) {
+	if signingKey == nil {
+		return nil, fmt.Errorf("Signing key isn't loaded")
+	}
+
+	jsig, err := libtrust.NewJSONSignature(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := jsig.Sign(signingKey); err != nil {
+		return nil, err
+	}
+
+	return jsig.PrettySignature("signatures")
+}
@@ -0,0 +1,303 @@
+package module
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/containerops/dockyard/models"
+	"github.com/containerops/wrench/setting"
+)
+
+// Bearer tokens are JWTs signed by the signing key of registry,
+// they grant the actions listed in access claim on the named resources to the subject.
+
+// permission levels required by the actions on repositories
+var actionPermissions = map[string]string{
+	"pull":   models.PermissionRead,
+	"push":   models.PermissionWrite,
+	"delete": models.PermissionAdmin,
+}
+
+// Access is the actions granted on a resource, e.g. repository:library/busybox:pull,push
+type Access struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+func (a Access) String() string {
+	return fmt.Sprintf("%v:%v:%v", a.Type, a.Name, strings.Join(a.Actions, ","))
+}
+
+// Allows reports whether action is granted
+func (a Access) Allows(action string) bool {
+	for _, granted := range a.Actions {
+		if granted == action || granted == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+type TokenClaims struct {
+	Issuer     string   `json:"iss"`
+	Subject    string   `json:"sub"`
+	Audience   string   `json:"aud"`
+	Expiration int64    `json:"exp"`
+	NotBefore  int64    `json:"nbf"`
+	IssuedAt   int64    `json:"iat"`
+	JWTID      string   `json:"jti"`
+	Access     []Access `json:"access"`
+}
+
+// Allows reports whether the token grants action on the resource
+func (c *TokenClaims) Allows(typ, name, action string) bool {
+	for _, access := range c.Access {
+		if access.Type == typ && access.Name == name && access.Allows(action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type tokenHeader struct {
+	Type      string `json:"typ"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// TokenInvalidError is returned when a bearer token is malformed, forged, expired or issued for other services
+type TokenInvalidError struct {
+	Reason string
+}
+
+func (e TokenInvalidError) Error() string {
+	return fmt.Sprintf("Invalid token: %v", e.Reason)
+}
+
+// ParseScope parses a scope of token request like repository:library/busybox:pull,push,
+// the name is kept as it is since it may contain a registry host with port.
+func ParseScope(scope string) (Access, error) {
+	first, last := strings.Index(scope, ":"), strings.LastIndex(scope, ":")
+	if first <= 0 || first == last || last == len(scope)-1 {
+		return Access{}, fmt.Errorf("Invalid scope: %v", scope)
+	}
+
+	return Access{
+		Type:    scope[:first],
+		Name:    scope[first+1 : last],
+		Actions: strings.Split(scope[last+1:], ","),
+	}, nil
+}
+
+// GrantAccess returns the requested actions allowed to user, an empty user is anonymous.
+// Repositories are pulled with read permission, pushed with write permission and deleted with admin permission,
+// the catalog is listed by authenticated users.
+func GrantAccess(user string, requested []Access) ([]Access, error) {
+	granted := []Access{}
+
+	for _, access := range requested {
+		actions := []string{}
+
+		switch {
+		case access.Type == "repository":
+			namespace, repository := SplitRepositoryName(access.Name)
+			level, err := RepositoryPermission(user, namespace, repository)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, action := range access.Actions {
+				if required, has := actionPermissions[action]; has && PermissionAllows(level, required) {
+					actions = append(actions, action)
+				}
+			}
+		case access.Type == "registry" && access.Name == "catalog" && user != "":
+			for _, action := range access.Actions {
+				if action == "*" {
+					actions = append(actions, action)
+				}
+			}
+		}
+
+		if len(actions) > 0 {
+			granted = append(granted, Access{Type: access.Type, Name: access.Name, Actions: actions})
+		}
+	}
+
+	return granted, nil
+}
+
+// LimitAccess keeps the access granted within the actions of scopes, nil scopes are unlimited.
+// The catalog is listed with pull scope.
+func LimitAccess(granted []Access, scopes []string) []Access {
+	if scopes == nil {
+		return granted
+	}
+
+	allowed := map[string]bool{}
+	for _, scope := range scopes {
+		allowed[scope] = true
+	}
+
+	limited := []Access{}
+	for _, access := range granted {
+		actions := []string{}
+		for _, action := range access.Actions {
+			if allowed[action] || (access.Type == "registry" && allowed["pull"]) {
+				actions = append(actions, action)
+			}
+		}
+
+		if len(actions) > 0 {
+			limited = append(limited, Access{Type: access.Type, Name: access.Name, Actions: actions})
+		}
+	}
+
+	return limited
+}
+
+// IssueToken signs a token of subject for service with the access granted
+func IssueToken(subject, service string, access []Access) (string, *TokenClaims, error) {
+	if signingKey == nil {
+		return "", nil, fmt.Errorf("Signing key isn't loaded")
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &TokenClaims{
+		Issuer:     setting.TokenIssuer,
+		Subject:    subject,
+		Audience:   service,
+		Expiration: now.Add(setting.TokenExpiration).Unix(),
+		NotBefore:  now.Unix(),
+		IssuedAt:   now.Unix(),
+		JWTID:      hex.EncodeToString(id),
+		Access:     access,
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	//the algorithm is decided by signing key, sign a placeholder to find it out
+	_, alg, err := signingKey.Sign(bytes.NewReader(nil), crypto.SHA256)
+	if err != nil {
+		return "", nil, err
+	}
+
+	header, err := json.Marshal(tokenHeader{Type: "JWT", Algorithm: alg, KeyID: signingKey.KeyID()})
+	if err != nil {
+		return "", nil, err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature, _, err := signingKey.Sign(strings.NewReader(signed), crypto.SHA256)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), claims, nil
+}
+
+// VerifyToken checks the signature, issuer, audience and validity period of token
+func VerifyToken(token, service string) (*TokenClaims, error) {
+	if signingKey == nil {
+		return nil, fmt.Errorf("Signing key isn't loaded")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, TokenInvalidError{Reason: "malformed token"}
+	}
+
+	var header tokenHeader
+	if data, err := base64.RawURLEncoding.DecodeString(parts[0]); err != nil {
+		return nil, TokenInvalidError{Reason: "malformed header"}
+	} else if err := json.Unmarshal(data, &header); err != nil {
+		return nil, TokenInvalidError{Reason: "malformed header"}
+	}
+
+	if header.KeyID != signingKey.KeyID() {
+		return nil, TokenInvalidError{Reason: "unknown signing key"}
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, TokenInvalidError{Reason: "malformed signature"}
+	}
+
+	if err := signingKey.PublicKey().Verify(strings.NewReader(parts[0]+"."+parts[1]), header.Algorithm, signature); err != nil {
+		return nil, TokenInvalidError{Reason: "signature mismatch"}
+	}
+
+	claims := new(TokenClaims)
+	if data, err := base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return nil, TokenInvalidError{Reason: "malformed claims"}
+	} else if err := json.Unmarshal(data, claims); err != nil {
+		return nil, TokenInvalidError{Reason: "malformed claims"}
+	}
+
+	now := time.Now().Unix()
+	switch {
+	case claims.Issuer != setting.TokenIssuer:
+		return nil, TokenInvalidError{Reason: "unknown issuer"}
+	case claims.Audience != service:
+		return nil, TokenInvalidError{Reason: "issued for other service"}
+	case now >= claims.Expiration:
+		return nil, TokenInvalidError{Reason: "token expired"}
+	case now < claims.NotBefore:
+		return nil, TokenInvalidError{Reason: "token not valid yet"}
+	}
+
+	return claims, nil
+}
+
+// V1Token returns the X-Docker-Token of V1 API, its signature is a bearer token granting actions on repository to user
+func V1Token(user, namespace, repository string, actions []string) (string, error) {
+	name := fmt.Sprintf("%v/%v", namespace, repository)
+
+	token, _, err := IssueToken(user, setting.TokenService, []Access{{Type: "repository", Name: name, Actions: actions}})
+	if err != nil {
+		return "", err
+	}
+
+	access := "read"
+	for _, action := range actions {
+		if action == "push" {
+			access = "write"
+		}
+	}
+
+	return fmt.Sprintf("Token signature=%v,repository=\"%v\",access=%v", token, name, access), nil
+}
+
+// VerifyV1Token verifies the token which docker sends back in Authorization header of V1 API
+func VerifyV1Token(authorization string) (*TokenClaims, error) {
+	if !strings.HasPrefix(authorization, "Token ") {
+		return nil, TokenInvalidError{Reason: "not a V1 token"}
+	}
+
+	for _, param := range strings.Split(strings.TrimPrefix(authorization, "Token "), ",") {
+		if parts := strings.SplitN(strings.TrimSpace(param), "=", 2); len(parts) == 2 && parts[0] == "signature" {
+			return VerifyToken(parts[1], setting.TokenService)
+		}
+	}
+
+	return nil, TokenInvalidError{Reason: "signature not found"}
+}
@@ -13,6 +13,7 @@ func SetRouters(m *macaron.Macaron) {
 
 		m.Get("/users", handler.GetUsersV1Handler)
 		m.Post("/users", handler.PostUsersV1Handler)
+		m.Put("/users/:username", handler.PutUserV1Handler)
 
 		m.Group("/repositories", func() {
 			m.Put("/:namespace/:repository/tags/:tag", handler.PutTagV1Handler)
@@ -35,14 +36,63 @@ func SetRouters(m *macaron.Macaron) {
 	//Docker Registry & Hub V2 API
 	m.Group("/v2", func() {
 		m.Get("/", handler.GetPingV2Handler)
-		m.Head("/:namespace/:repository/blobs/:digest", handler.HeadBlobsV2Handler)
-		m.Post("/:namespace/:repository/blobs/uploads", handler.PostBlobsV2Handler)
-		m.Patch("/:namespace/:repository/blobs/uploads/:uuid", handler.PatchBlobsV2Handler)
-		m.Put("/:namespace/:repository/blobs/uploads/:uuid", handler.PutBlobsV2Handler)
-		m.Get("/:namespace/:repository/blobs/:digest", handler.GetBlobsV2Handler)
-		m.Put("/:namespace/:repository/manifests/:tag", handler.PutManifestsV2Handler)
-		m.Get("/:namespace/:repository/tags/list", handler.GetTagsListV2Handler)
-		m.Get("/:namespace/:repository/manifests/:tag", handler.GetManifestsV2Handler)
+		m.Get("/token", handler.GetTokenV2Handler)
+		m.Get("/_catalog", handler.GetCatalogV2Handler)
+		m.Head("/:name/blobs/:digest", handler.HeadBlobsV2Handler)
+		m.Post("/:name/blobs/uploads", handler.PostBlobsV2Handler)
+		m.Patch("/:name/blobs/uploads/:uuid", handler.PatchBlobsV2Handler)
+		m.Put("/:name/blobs/uploads/:uuid", handler.PutBlobsV2Handler)
+		m.Get("/:name/blobs/uploads/:uuid", handler.GetBlobsUploadV2Handler)
+		m.Delete("/:name/blobs/uploads/:uuid", handler.DeleteBlobsUploadV2Handler)
+		m.Get("/:name/blobs/:digest", handler.GetBlobsV2Handler)
+		m.Delete("/:name/blobs/:digest", handler.DeleteBlobsV2Handler)
+		m.Put("/:name/manifests/:tag", handler.PutManifestsV2Handler)
+		m.Get("/:name/tags/list", handler.GetTagsListV2Handler)
+		m.Get("/:name/manifests/:tag", handler.GetManifestsV2Handler)
+		m.Head("/:name/manifests/:tag", handler.HeadManifestsV2Handler)
+		m.Delete("/:name/manifests/:tag", handler.DeleteManifestsV2Handler)
+		m.Get("/:name/referrers/:digest", handler.GetReferrersV2Handler)
+	})
+
+	//Organizations and teams
+	m.Group("/orgs", func() {
+		m.Post("/", handler.PostOrganizationHandler)
+		m.Get("/:org", handler.GetOrganizationHandler)
+		m.Post("/:org/teams", handler.PostTeamHandler)
+		m.Get("/:org/teams/:team", handler.GetTeamHandler)
+		m.Put("/:org/teams/:team/members/:username", handler.PutTeamMemberHandler)
+		m.Delete("/:org/teams/:team/members/:username", handler.DeleteTeamMemberHandler)
+	})
+
+	//Visibility and permissions of repository, the repository is named by query string
+	m.Group("/permissions", func() {
+		m.Get("/", handler.GetPermissionsHandler)
+		m.Put("/visibility", handler.PutVisibilityHandler)
+		m.Put("/users/:username", handler.PutUserPermissionHandler)
+		m.Delete("/users/:username", handler.DeleteUserPermissionHandler)
+		m.Put("/teams/:org/:team", handler.PutTeamPermissionHandler)
+		m.Delete("/teams/:org/:team", handler.DeleteTeamPermissionHandler)
+	})
+
+	//Owner of namespace
+	m.Get("/namespaces/:namespace", handler.GetNamespaceHandler)
+
+	//Robots of namespace
+	m.Get("/namespaces/:namespace/robots", handler.GetRobotsHandler)
+	m.Post("/namespaces/:namespace/robots", handler.PostRobotHandler)
+	m.Delete("/namespaces/:namespace/robots/:robot", handler.DeleteRobotHandler)
+
+	//Access tokens of user
+	m.Group("/tokens", func() {
+		m.Get("/", handler.GetAccessTokensHandler)
+		m.Post("/", handler.PostAccessTokenHandler)
+		m.Delete("/:id", handler.DeleteAccessTokenHandler)
+	})
+
+	//Replication status of rules for administrators
+	m.Group("/replication", func() {
+		m.Get("/", handler.GetReplicationHandler)
+		m.Get("/:rule", handler.GetReplicationRuleHandler)
 	})
 
 	//Rkt Registry & Hub API
@@ -8,6 +8,7 @@ import (
 
 	"github.com/containerops/dockyard/backend"
 	"github.com/containerops/dockyard/middleware"
+	"github.com/containerops/dockyard/module"
 	"github.com/containerops/dockyard/router"
 	"github.com/containerops/wrench/db"
 	"github.com/containerops/wrench/setting"
@@ -27,12 +28,22 @@ func SetDockyardMacaron(m *macaron.Macaron) {
 		fmt.Printf("Init middleware error %s", err.Error())
 	}
 
+	if err := module.LoadSigningKey(setting.SigningKey); err != nil {
+		fmt.Printf("Load signing key error %s", err.Error())
+	}
+
 	//Setting Middleware
 	middleware.SetMiddlewares(m)
 
 	//Setting Router
 	router.SetRouters(m)
 
+	//Purge abandoned upload sessions in background
+	module.StartUploadPurger(setting.ImagePath, setting.UploadPurgeAge, setting.UploadPurgeInterval, middleware.Log)
+
+	//Remove unreferenced blobs in background
+	module.StartGC(setting.GCGracePeriod, setting.GCInterval, middleware.Log)
+
 	//Create acpool to store aci/asc/pubkey
 	err := func() error {
 		acpoolname := setting.ImagePath + "/acpool"